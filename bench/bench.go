@@ -0,0 +1,112 @@
+//go:build !tinygo && !wasip1
+
+// Package bench runs the snailtracer benchmark on its backends and records
+// machine-readable results.
+package bench
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer/backends"
+)
+
+// Config selects how a backend is benchmarked.
+type Config struct {
+	Iterations int // Measured iterations
+	Warmup     int // Unmeasured iterations run before the measured ones
+	Seed       int // Seed passed to every run
+}
+
+// Sample is the measurement of a single benchmark iteration.
+type Sample struct {
+	Nanoseconds int64  `json:"ns"`
+	Allocs      uint64 `json:"allocs"`
+	Bytes       uint64 `json:"bytes"`
+	Gas         uint64 `json:"gas,omitempty"` // Only set by gas metered backends
+	// Instructions is only set by instruction metered Wasm backends.
+	Instructions uint64 `json:"instructions,omitempty"`
+}
+
+// Result holds the samples and summary of one benchmarked backend.
+type Result struct {
+	Name    string   `json:"name"`
+	Samples []Sample `json:"samples"`
+	Summary Summary  `json:"summary"`
+	// PixelGas breaks the gas down by benchmark pixel for the EVM backend.
+	PixelGas []backends.PixelGas `json:"pixel_gas,omitempty"`
+	// Deployment is the cost of deploying the contract for the EVM backend.
+	Deployment *backends.EVMDeployment `json:"deployment,omitempty"`
+}
+
+// Run benchmarks a backend that has already been set up. Every run, warmup
+// included, must produce the expected benchmark color.
+func Run(backend backends.Backend, config Config) (*Result, error) {
+	if config.Iterations < 1 {
+		return nil, fmt.Errorf("invalid iteration count %d", config.Iterations)
+	}
+	for i := 0; i < config.Warmup; i++ {
+		if err := runOnce(backend, config.Seed); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		samples = make([]Sample, config.Iterations)
+		before  runtime.MemStats
+		after   runtime.MemStats
+
+		gasReporter, meterGas                  = backend.(backends.GasReporter)
+		instructionReporter, meterInstructions = backend.(backends.InstructionReporter)
+	)
+	for i := range samples {
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		err := runOnce(backend, config.Seed)
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			return nil, err
+		}
+		samples[i] = Sample{
+			Nanoseconds: elapsed.Nanoseconds(),
+			Allocs:      after.Mallocs - before.Mallocs,
+			Bytes:       after.TotalAlloc - before.TotalAlloc,
+		}
+		if meterGas {
+			samples[i].Gas = gasReporter.GasUsed()
+		}
+		if meterInstructions {
+			samples[i].Instructions = instructionReporter.InstructionsUsed()
+		}
+	}
+	result := &Result{
+		Name:    backend.Name(),
+		Samples: samples,
+		Summary: Summarize(samples),
+	}
+	if reporter, ok := backend.(backends.PixelGasReporter); ok {
+		pixels, err := reporter.PixelGas()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", backend.Name(), err)
+		}
+		result.PixelGas = pixels
+	}
+	if reporter, ok := backend.(backends.DeploymentReporter); ok {
+		deployment := reporter.Deployment()
+		result.Deployment = &deployment
+	}
+	return result, nil
+}
+
+func runOnce(backend backends.Backend, seed int) error {
+	r, g, b, err := backend.Run(seed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", backend.Name(), err)
+	}
+	if !snailtracer.ValidBenchmarkResult(r, g, b) {
+		return fmt.Errorf("%s: invalid result: %d %d %d", backend.Name(), r, g, b)
+	}
+	return nil
+}
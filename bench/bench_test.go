@@ -0,0 +1,47 @@
+//go:build !tinygo && !wasip1
+
+package bench
+
+import (
+	"testing"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer/backends"
+)
+
+// gasBackend returns the benchmark color and reports gas like the EVM backend.
+type gasBackend struct{}
+
+func (gasBackend) Name() string                           { return "gas" }
+func (gasBackend) Setup() error                           { return nil }
+func (gasBackend) Run(seed int) (r, g, b byte, err error) { return 17, 17, 53, nil }
+func (gasBackend) Close() error                           { return nil }
+func (gasBackend) GasUsed() uint64                        { return 100 }
+
+func (gasBackend) PixelGas() ([]backends.PixelGas, error) {
+	return []backends.PixelGas{{X: 1, Y: 2, SPP: 3, Gas: 25}}, nil
+}
+
+func (gasBackend) Deployment() backends.EVMDeployment {
+	return backends.EVMDeployment{InitGas: 10}
+}
+
+func TestRunReporters(t *testing.T) {
+	result, err := Run(gasBackend{}, Config{Iterations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary.GasPerOp != 100 || len(result.PixelGas) != 1 || result.PixelGas[0].Gas != 25 || result.Deployment == nil || result.Deployment.InitGas != 10 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	native := backends.NewNativeBackend("native", 1)
+	if err := native.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = Run(native, Config{Iterations: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if result.PixelGas != nil || result.Deployment != nil {
+		t.Errorf("native backend reported gas %v, deployment %v", result.PixelGas, result.Deployment)
+	}
+}
@@ -0,0 +1,159 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// Comparison is the difference between a benchmark's samples in two reports.
+type Comparison struct {
+	Name     string
+	Old, New Summary
+	OldN     int
+	NewN     int
+	// Delta is the relative change of the median time from old to new, e.g.
+	// 0.1 for a 10% slowdown.
+	Delta float64
+	// P is the two-sided p-value of the Mann-Whitney U test that the samples
+	// come from the same distribution.
+	P float64
+	// Significant is set when P is below the significance level.
+	Significant bool
+}
+
+// Regression reports whether the comparison is a significant slowdown by more
+// than threshold, e.g. 0.05 for 5%.
+func (c *Comparison) Regression(threshold float64) bool {
+	return c.Significant && c.Delta > threshold
+}
+
+// Compare compares the benchmarks present in both the base and the head report,
+// in the order of head, at significance level alpha.
+func Compare(base, head *Report, alpha float64) []Comparison {
+	bases := make(map[string]*Result)
+	for _, result := range base.Results {
+		bases[result.Name] = result
+	}
+	var comparisons []Comparison
+	for _, h := range head.Results {
+		b, ok := bases[h.Name]
+		if !ok {
+			continue
+		}
+		x, y := nanoseconds(b.Samples), nanoseconds(h.Samples)
+		c := Comparison{
+			Name: h.Name,
+			Old:  Summarize(b.Samples),
+			New:  Summarize(h.Samples),
+			OldN: len(x),
+			NewN: len(y),
+			P:    MannWhitneyU(x, y),
+		}
+		c.Delta = c.New.Median/c.Old.Median - 1
+		c.Significant = c.P < alpha
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+func nanoseconds(samples []Sample) []float64 {
+	xs := make([]float64, len(samples))
+	for i, s := range samples {
+		xs[i] = float64(s.Nanoseconds)
+	}
+	return xs
+}
+
+// MannWhitneyU returns the two-sided p-value of the Mann-Whitney U test on the
+// samples x and y, using the normal approximation with tie and continuity
+// correction. It returns 1 when either sample is empty or all values are tied.
+func MannWhitneyU(x, y []float64) float64 {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type value struct {
+		v     float64
+		first bool
+	}
+	values := make([]value, 0, len(x)+len(y))
+	for _, v := range x {
+		values = append(values, value{v, true})
+	}
+	for _, v := range y {
+		values = append(values, value{v, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].v < values[j].v })
+
+	// Rank the values, averaging the ranks of ties, and sum the ranks of x.
+	var rankSum, ties float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	u := rankSum - n1*(n1+1)/2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	z := math.Abs(u-n1*n2/2) - 0.5
+	if z < 0 {
+		z = 0
+	}
+	z /= math.Sqrt(variance)
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// WriteComparisons writes the comparisons as a table in the style of
+// benchstat: the median time with its relative standard deviation for both
+// reports, the delta and the p-value. Deltas that are not significant are
+// shown as "~".
+func WriteComparisons(w io.Writer, comparisons []Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\told time/op\tnew time/op\tdelta\t")
+	for _, c := range comparisons {
+		delta := "~"
+		if c.Significant {
+			delta = fmt.Sprintf("%+.2f%%", 100*c.Delta)
+		}
+		fmt.Fprintf(tw, "%s\t%s ±%2.0f%%\t%s ±%2.0f%%\t%s\t(p=%.3f n=%d+%d)\n",
+			c.Name, formatNs(c.Old.Median), relStddev(c.Old), formatNs(c.New.Median), relStddev(c.New),
+			delta, c.P, c.OldN, c.NewN)
+	}
+	return tw.Flush()
+}
+
+func relStddev(s Summary) float64 {
+	if s.Mean == 0 {
+		return 0
+	}
+	return 100 * s.Stddev / s.Mean
+}
+
+// formatNs formats a duration in nanoseconds with a unit suited to its size.
+func formatNs(ns float64) string {
+	switch {
+	case ns >= 1e9:
+		return fmt.Sprintf("%.2fs", ns/1e9)
+	case ns >= 1e6:
+		return fmt.Sprintf("%.2fms", ns/1e6)
+	case ns >= 1e3:
+		return fmt.Sprintf("%.2fµs", ns/1e3)
+	}
+	return fmt.Sprintf("%.0fns", ns)
+}
@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		x, y []float64
+		want float64
+	}{
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.012185780355344818},
+		{[]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 0.012185780355344818},
+		{[]float64{1, 3, 5}, []float64{2, 4, 6}, 0.6625205835400574},
+		{[]float64{1, 1, 1}, []float64{1, 1}, 1},
+		{nil, []float64{1}, 1},
+	}
+	for i, tt := range tests {
+		if have := MannWhitneyU(tt.x, tt.y); math.Abs(have-tt.want) > 1e-9 {
+			t.Errorf("test %d: have p=%v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func newTestResult(name string, ns ...int64) *Result {
+	result := &Result{Name: name}
+	for _, n := range ns {
+		result.Samples = append(result.Samples, Sample{Nanoseconds: n})
+	}
+	return result
+}
+
+func TestCompare(t *testing.T) {
+	old := &Report{Results: []*Result{
+		newTestResult("native", 100, 101, 99, 100, 102, 98),
+		newTestResult("evm", 1000, 1010, 990, 1005, 995, 1000),
+		newTestResult("removed", 1),
+	}}
+	new := &Report{Results: []*Result{
+		newTestResult("native", 120, 121, 119, 120, 122, 118),
+		newTestResult("evm", 1001, 1009, 991, 1004, 996, 999),
+		newTestResult("added", 1),
+	}}
+	comparisons := Compare(old, new, 0.05)
+	if len(comparisons) != 2 {
+		t.Fatalf("have %d comparisons, want 2", len(comparisons))
+	}
+	native, evm := comparisons[0], comparisons[1]
+	if !native.Significant || math.Abs(native.Delta-0.2) > 1e-9 || !native.Regression(0.1) || native.Regression(0.25) {
+		t.Errorf("native: unexpected comparison %+v", native)
+	}
+	if evm.Significant || evm.Regression(0) {
+		t.Errorf("evm: unexpected comparison %+v", evm)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteComparisons(&buf, comparisons); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "+20.00%") || !strings.Contains(lines[2], "~") {
+		t.Errorf("unexpected table:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,231 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is the full output of a benchmark session.
+type Report struct {
+	Metadata Metadata  `json:"metadata"`
+	Results  []*Result `json:"results"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// csvHeader are the columns of a CSV report. Every sample is one row numbered
+// by its iteration, followed by one row per summary statistic of the backend
+// with the statistic's name in the iteration column. The gas and instructions
+// columns are empty for backends that are not metered.
+var csvHeader = []string{"name", "iteration", "ns", "allocs", "bytes", "gas", "instructions"}
+
+// WriteCSV writes the report as CSV. The metadata precedes the header as
+// "# key: value" comment lines.
+func (r *Report) WriteCSV(w io.Writer) error {
+	m := r.Metadata
+	for _, kv := range [][2]string{
+		{"time", m.Time.Format(time.RFC3339)},
+		{"go_version", m.GoVersion},
+		{"os", m.OS},
+		{"arch", m.Arch},
+		{"cpu", m.CPU},
+		{"cores", strconv.Itoa(m.Cores)},
+		{"revision", m.Revision},
+	} {
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	f := func(x float64) string { return strconv.FormatFloat(x, 'f', -1, 64) }
+	for _, result := range r.Results {
+		for i, s := range result.Samples {
+			gas, instructions := "", ""
+			if s.Gas > 0 {
+				gas = strconv.FormatUint(s.Gas, 10)
+			}
+			if s.Instructions > 0 {
+				instructions = strconv.FormatUint(s.Instructions, 10)
+			}
+			cw.Write([]string{
+				result.Name,
+				strconv.Itoa(i),
+				strconv.FormatInt(s.Nanoseconds, 10),
+				strconv.FormatUint(s.Allocs, 10),
+				strconv.FormatUint(s.Bytes, 10),
+				gas,
+				instructions,
+			})
+		}
+		sum := result.Summary
+		gas, instructions := "", ""
+		if sum.GasPerOp > 0 {
+			gas = f(sum.GasPerOp)
+		}
+		if sum.InstructionsPerOp > 0 {
+			instructions = f(sum.InstructionsPerOp)
+		}
+		cw.Write([]string{result.Name, "mean", f(sum.Mean), f(sum.AllocsPerOp), f(sum.BytesPerOp), gas, instructions})
+		cw.Write([]string{result.Name, "median", f(sum.Median), "", "", "", ""})
+		cw.Write([]string{result.Name, "stddev", f(sum.Stddev), "", "", "", ""})
+		cw.Write([]string{result.Name, "p95", f(sum.P95), "", "", "", ""})
+		if sum.NsPerGas > 0 {
+			cw.Write([]string{result.Name, "ns/gas", f(sum.NsPerGas), "", "", "", ""})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// legacyCSVHeader are the columns of the results written by `go test -bench`
+// before cmd/bench, one row per Go benchmark averaged over its iterations.
+var legacyCSVHeader = []string{"Benchmark", "Iterations", "ns/op", "Bytes/op", "Allocs/op"}
+
+// ReadReport reads a report written by WriteJSON or WriteCSV, telling the
+// formats apart by their first character. Legacy CSV results are read as one
+// sample per backend, its averages. The summaries are recomputed from the
+// samples.
+func ReadReport(r io.Reader) (*Report, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var report *Report
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		report = new(Report)
+		err = json.Unmarshal(data, report)
+	} else {
+		report, err = readCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range report.Results {
+		result.Summary = Summarize(result.Samples)
+	}
+	return report, nil
+}
+
+func readCSV(data []byte) (*Report, error) {
+	report := new(Report)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "# ") {
+			break
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(line, "# "), ": ")
+		m := &report.Metadata
+		switch key {
+		case "time":
+			m.Time, _ = time.Parse(time.RFC3339, value)
+		case "go_version":
+			m.GoVersion = value
+		case "os":
+			m.OS = value
+		case "arch":
+			m.Arch = value
+		case "cpu":
+			m.CPU = value
+		case "cores":
+			m.Cores, _ = strconv.Atoi(value)
+		case "revision":
+			m.Revision = value
+		}
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comment = '#'
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.Join(records[0], ",") == strings.Join(legacyCSVHeader, ",") {
+		return readLegacyCSV(records[1:])
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, fmt.Errorf("not a benchmark report: want header %q", strings.Join(csvHeader, ","))
+	}
+	results := make(map[string]*Result)
+	for i, record := range records[1:] {
+		if _, err := strconv.Atoi(record[1]); err != nil {
+			continue // Summary row
+		}
+		ns, errNs := strconv.ParseInt(record[2], 10, 64)
+		allocs, errAllocs := strconv.ParseUint(record[3], 10, 64)
+		bytes, errBytes := strconv.ParseUint(record[4], 10, 64)
+		var gas, instructions uint64
+		var errGas, errInstructions error
+		if record[5] != "" {
+			gas, errGas = strconv.ParseUint(record[5], 10, 64)
+		}
+		if record[6] != "" {
+			instructions, errInstructions = strconv.ParseUint(record[6], 10, 64)
+		}
+		if errNs != nil || errAllocs != nil || errBytes != nil || errGas != nil || errInstructions != nil {
+			return nil, fmt.Errorf("record %d: invalid sample %v", i+2, record)
+		}
+		result, ok := results[record[0]]
+		if !ok {
+			result = &Result{Name: record[0]}
+			results[record[0]] = result
+			report.Results = append(report.Results, result)
+		}
+		result.Samples = append(result.Samples, Sample{Nanoseconds: ns, Allocs: allocs, Bytes: bytes, Gas: gas, Instructions: instructions})
+	}
+	return report, nil
+}
+
+// readLegacyCSV reads the rows of legacy CSV results, named after the backends
+// their benchmarks ran.
+func readLegacyCSV(records [][]string) (*Report, error) {
+	report := new(Report)
+	for i, record := range records {
+		ns, errNs := strconv.ParseInt(record[2], 10, 64)
+		bytes, errBytes := strconv.ParseUint(record[3], 10, 64)
+		allocs, errAllocs := strconv.ParseUint(record[4], 10, 64)
+		if errNs != nil || errBytes != nil || errAllocs != nil {
+			return nil, fmt.Errorf("record %d: invalid legacy result %v", i+2, record)
+		}
+		report.Results = append(report.Results, &Result{
+			Name:    legacyBackendName(record[0]),
+			Samples: []Sample{{Nanoseconds: ns, Allocs: allocs, Bytes: bytes}},
+		})
+	}
+	return report, nil
+}
+
+// legacyBackendName returns the name of the backend run by a legacy Go
+// benchmark, e.g. wazero/compiler/o2 for
+// BenchmarkTinygoSnailtracer/wazero/compiler/o2-8.
+func legacyBackendName(benchmark string) string {
+	name := strings.TrimPrefix(benchmark, "Benchmark")
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i] // GOMAXPROCS suffix
+		}
+	}
+	switch name {
+	case "NativeSnailtracer":
+		return "native"
+	case "Parallel4NativeSnailtracer":
+		return "native/parallel4"
+	case "EVMSnailtracer":
+		return "evm"
+	}
+	return strings.TrimPrefix(name, "TinygoSnailtracer/")
+}
@@ -0,0 +1,128 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	samples := []Sample{{Nanoseconds: 10, Allocs: 1, Bytes: 8, Instructions: 40}, {Nanoseconds: 20, Allocs: 1, Bytes: 8, Instructions: 40}}
+	gasSamples := []Sample{{Nanoseconds: 100, Gas: 50}, {Nanoseconds: 200, Gas: 50}}
+	return &Report{
+		Metadata: Metadata{Time: time.Unix(0, 0).UTC(), GoVersion: "go1.19", CPU: "test", Cores: 4, Revision: "abc"},
+		Results: []*Result{
+			{Name: "wazero", Samples: samples, Summary: Summarize(samples)},
+			{Name: "evm", Samples: gasSamples, Summary: Summarize(gasSamples)},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Metadata.Revision != "abc" || len(report.Results) != 2 || report.Results[0].Summary.Mean != 15 || report.Results[1].Summary.NsPerGas != 3 {
+		t.Errorf("unexpected round trip %+v", report)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r := csv.NewReader(&buf)
+	r.Comment = '#'
+	r.FieldsPerRecord = len(csvHeader)
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header, then two samples and four summary rows per result plus the
+	// ns/gas row of the gas metered one.
+	if len(records) != 14 {
+		t.Fatalf("have %d records, want 14", len(records))
+	}
+	if have := records[2]; have[0] != "wazero" || have[1] != "1" || have[2] != "20" || have[6] != "40" {
+		t.Errorf("unexpected sample row %v", have)
+	}
+	if have := records[3]; have[1] != "mean" || have[2] != "15" || have[6] != "40" {
+		t.Errorf("unexpected mean row %v", have)
+	}
+	if have := records[8]; have[0] != "evm" || have[5] != "50" {
+		t.Errorf("unexpected gas sample row %v", have)
+	}
+	if have := records[13]; have[1] != "ns/gas" || have[2] != "3" {
+		t.Errorf("unexpected ns/gas row %v", have)
+	}
+}
+
+func TestReadReport(t *testing.T) {
+	want := testReport()
+	for _, format := range []struct {
+		name  string
+		write func(*Report, *bytes.Buffer) error
+	}{
+		{"json", func(r *Report, buf *bytes.Buffer) error { return r.WriteJSON(buf) }},
+		{"csv", func(r *Report, buf *bytes.Buffer) error { return r.WriteCSV(buf) }},
+	} {
+		var buf bytes.Buffer
+		if err := format.write(want, &buf); err != nil {
+			t.Fatal(err)
+		}
+		have, err := ReadReport(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		if have.Metadata != want.Metadata {
+			t.Errorf("%s: have metadata %+v, want %+v", format.name, have.Metadata, want.Metadata)
+		}
+		if len(have.Results) != 2 || len(have.Results[1].Samples) != 2 || have.Results[0].Summary != want.Results[0].Summary || have.Results[1].Summary != want.Results[1].Summary {
+			t.Errorf("%s: unexpected results %+v", format.name, have.Results)
+		}
+	}
+	if _, err := ReadReport(strings.NewReader("a,b\n1,2\n")); err == nil {
+		t.Error("expected error for foreign CSV")
+	}
+}
+
+// TestReadLegacyReport reads the results committed before cmd/bench.
+func TestReadLegacyReport(t *testing.T) {
+	file, err := os.Open("../results/benchmark_results.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	report, err := ReadReport(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 11 {
+		t.Fatalf("have %d results, want 11", len(report.Results))
+	}
+	means := make(map[string]float64)
+	for _, result := range report.Results {
+		means[result.Name] = result.Summary.Mean
+	}
+	for name, want := range map[string]float64{
+		"native":               14870718,
+		"native/parallel4":     4526680,
+		"evm":                  365506517,
+		"wasmer/singlepass/o2": 115009724,
+		"wazero/compiler/oz":   262094354,
+	} {
+		if have, ok := means[name]; !ok || have != want {
+			t.Errorf("%s: have mean %v, want %v", name, have, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary describes the distribution of a backend's samples. Times are in
+// nanoseconds per iteration.
+type Summary struct {
+	Mean        float64 `json:"mean_ns"`
+	Median      float64 `json:"median_ns"`
+	Stddev      float64 `json:"stddev_ns"`
+	P95         float64 `json:"p95_ns"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+	GasPerOp    float64 `json:"gas_per_op,omitempty"`
+	NsPerGas    float64 `json:"ns_per_gas,omitempty"`
+
+	InstructionsPerOp float64 `json:"instructions_per_op,omitempty"`
+}
+
+// Summarize computes the summary statistics of the samples.
+func Summarize(samples []Sample) Summary {
+	if len(samples) == 0 {
+		return Summary{}
+	}
+	times := make([]float64, len(samples))
+	var allocs, bytes, gas, instructions float64
+	for i, s := range samples {
+		times[i] = float64(s.Nanoseconds)
+		allocs += float64(s.Allocs)
+		bytes += float64(s.Bytes)
+		gas += float64(s.Gas)
+		instructions += float64(s.Instructions)
+	}
+	n := float64(len(samples))
+	mean, stddev := meanStddev(times)
+	sort.Float64s(times)
+	summary := Summary{
+		Mean:        mean,
+		Median:      percentile(times, 50),
+		Stddev:      stddev,
+		P95:         percentile(times, 95),
+		AllocsPerOp: allocs / n,
+		BytesPerOp:  bytes / n,
+	}
+	if gas > 0 {
+		summary.GasPerOp = gas / n
+		summary.NsPerGas = mean / summary.GasPerOp
+	}
+	if instructions > 0 {
+		summary.InstructionsPerOp = instructions / n
+	}
+	return summary
+}
+
+// meanStddev returns the mean and sample standard deviation of xs.
+func meanStddev(xs []float64) (mean, stddev float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sum / float64(len(xs)-1))
+}
+
+// percentile returns the p-th percentile of the sorted xs, interpolating
+// linearly between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
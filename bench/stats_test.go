@@ -0,0 +1,48 @@
+package bench
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	var samples []Sample
+	for _, ns := range []int64{5, 1, 4, 2, 3} {
+		samples = append(samples, Sample{Nanoseconds: ns, Allocs: uint64(ns), Bytes: 10 * uint64(ns), Gas: 100 * uint64(ns)})
+	}
+	sum := Summarize(samples)
+	want := Summary{
+		Mean:        3,
+		Median:      3,
+		Stddev:      math.Sqrt(2.5),
+		P95:         4.8,
+		AllocsPerOp: 3,
+		BytesPerOp:  30,
+		GasPerOp:    300,
+		NsPerGas:    0.01,
+	}
+	for _, c := range []struct {
+		name       string
+		have, want float64
+	}{
+		{"mean", sum.Mean, want.Mean},
+		{"median", sum.Median, want.Median},
+		{"stddev", sum.Stddev, want.Stddev},
+		{"p95", sum.P95, want.P95},
+		{"allocs", sum.AllocsPerOp, want.AllocsPerOp},
+		{"bytes", sum.BytesPerOp, want.BytesPerOp},
+		{"gas", sum.GasPerOp, want.GasPerOp},
+		{"ns/gas", sum.NsPerGas, want.NsPerGas},
+	} {
+		if math.Abs(c.have-c.want) > 1e-9 {
+			t.Errorf("%s: have %v, want %v", c.name, c.have, c.want)
+		}
+	}
+}
+
+func TestSummarizeSingle(t *testing.T) {
+	sum := Summarize([]Sample{{Nanoseconds: 7}})
+	if sum.Mean != 7 || sum.Median != 7 || sum.P95 != 7 || sum.Stddev != 0 || sum.GasPerOp != 0 || sum.NsPerGas != 0 || sum.InstructionsPerOp != 0 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+}
@@ -0,0 +1,280 @@
+// Command bench runs the snailtracer benchmark on the selected backends and
+// writes the results as JSON and CSV.
+//
+// Usage:
+//
+//	bench [flags]                  run the benchmarks
+//	bench compare [flags] old new  compare two result files
+//	bench profile [flags]          profile the EVM backend by opcode
+//
+// bench compare exits with status 1 if a benchmark regressed and with status 2
+// if it could not compare the files, on usage, I/O and parse errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/therealbytes/snailtracer-benchmark/bench"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer/backends"
+)
+
+var (
+	backendsFlag   = flag.String("backends", "", "comma separated backends to run (default all)")
+	listFlag       = flag.Bool("list", false, "list the available backends and exit")
+	iterationsFlag = flag.Int("n", 10, "measured iterations per backend")
+	warmupFlag     = flag.Int("warmup", 1, "unmeasured iterations run before measuring")
+	seedFlag       = flag.Int("seed", 0, "seed passed to the benchmark")
+	evmEIPsFlag    = flag.String("evm.eips", "", "comma separated EIPs to enable on top of the fork of every EVM backend")
+	wasmMeterFlag  = flag.Bool("wasm.meter", false, "count the instructions executed by the Wasm backends")
+	wasmFuelFlag   = flag.Uint64("wasm.fuel", 0, "abort Wasm runs executing more instructions, implies -wasm.meter (default unlimited)")
+	artifactsFlag  = flag.String("artifacts", "snailtracer/testdata", "directory holding the compiled contract and Wasm modules")
+	jsonFlag       = flag.String("json", "", "write JSON results to this file, - for stdout")
+	csvFlag        = flag.String("csv", "", "write CSV results to this file, - for stdout")
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			os.Exit(compare(os.Args[2:]))
+		case "profile":
+			profile(os.Args[2:])
+			return
+		}
+	}
+	flag.Parse()
+
+	if *listFlag {
+		for _, name := range backends.BackendNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	artifacts, err := backends.LoadArtifacts(*artifactsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	names := backends.BackendNames()
+	explicit := *backendsFlag != ""
+	if explicit {
+		names = strings.Split(*backendsFlag, ",")
+	}
+	config := bench.Config{Iterations: *iterationsFlag, Warmup: *warmupFlag, Seed: *seedFlag}
+	opts := options{wasmMeter: *wasmMeterFlag || *wasmFuelFlag > 0, wasmFuel: *wasmFuelFlag}
+	if *evmEIPsFlag != "" {
+		for _, s := range strings.Split(*evmEIPsFlag, ",") {
+			eip, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				log.Fatalf("invalid EIP %q", s)
+			}
+			opts.eips = append(opts.eips, eip)
+		}
+	}
+
+	report := &bench.Report{Metadata: bench.CollectMetadata()}
+	for _, name := range names {
+		result, err := run(strings.TrimSpace(name), artifacts, opts, config)
+		if errors.Is(err, backends.ErrMissingArtifact) && !explicit {
+			log.Printf("skipping: %v", err)
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		sum := result.Summary
+		line := fmt.Sprintf("%-24s mean %12.0f ns/op  median %12.0f ns/op  stddev %5.2f%%",
+			result.Name, sum.Mean, sum.Median, 100*sum.Stddev/sum.Mean)
+		if sum.GasPerOp > 0 {
+			line += fmt.Sprintf("  %12.0f gas/op  %8.3f ns/gas", sum.GasPerOp, sum.NsPerGas)
+		}
+		if sum.InstructionsPerOp > 0 {
+			line += fmt.Sprintf("  %12.0f instructions/op", sum.InstructionsPerOp)
+		}
+		log.Print(line)
+		if d := result.Deployment; d != nil && d.Created {
+			log.Printf("%-24s deployment: %d gas (+%d intrinsic) in %s, Init: %d gas in %s", "", d.Gas, d.IntrinsicGas, d.Time, d.InitGas, d.InitTime)
+		}
+		for _, p := range result.PixelGas {
+			log.Printf("%-24s pixel (%d, %d) at %d spp: %d gas", "", p.X, p.Y, p.SPP, p.Gas)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if err := write(*jsonFlag, report.WriteJSON); err != nil {
+		log.Fatal(err)
+	}
+	if err := write(*csvFlag, report.WriteCSV); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// options configure the backends before they are set up.
+type options struct {
+	eips      []int  // Enabled on EVM backends
+	wasmMeter bool   // Meter the instructions of Wasm backends
+	wasmFuel  uint64 // Instruction limit of metered Wasm backends
+}
+
+// run sets up the named backend with the options, benchmarks it and closes it.
+func run(name string, artifacts backends.Artifacts, opts options, config bench.Config) (*bench.Result, error) {
+	backend, err := backends.NewBackend(name, artifacts)
+	if err != nil {
+		return nil, err
+	}
+	if evm, ok := backend.(*backends.EVMBackend); ok && len(opts.eips) > 0 {
+		evm.SetExtraEIPs(opts.eips)
+	}
+	if meter, ok := backend.(backends.InstructionMeter); ok && opts.wasmMeter {
+		meter.SetMetering(opts.wasmFuel)
+	}
+	if err := backend.Setup(); err != nil {
+		return nil, err
+	}
+	defer backend.Close()
+	return bench.Run(backend, config)
+}
+
+// write writes the report to path with fn, skipping empty paths.
+func write(path string, fn func(io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return fn(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Exit codes of the compare subcommand.
+const (
+	exitRegression = 1 // A benchmark regressed significantly by more than the threshold
+	exitError      = 2 // Usage, I/O or parse error
+)
+
+// compare runs the compare subcommand and returns the exit code, exitRegression
+// or exitError, or 0 if no benchmark regressed.
+func compare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	alpha := fs.Float64("alpha", 0.05, "significance level of the Mann-Whitney U test")
+	threshold := fs.Float64("threshold", 5, "regression threshold of the median time in percent")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bench compare [flags] old new")
+		fmt.Fprintln(fs.Output(), "Exits with status 1 on a regression and 2 on errors.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	base, err := readReport(fs.Arg(0))
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	head, err := readReport(fs.Arg(1))
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+	comparisons := bench.Compare(base, head, *alpha)
+	if err := bench.WriteComparisons(os.Stdout, comparisons); err != nil {
+		log.Print(err)
+		return exitError
+	}
+	code := 0
+	for _, c := range comparisons {
+		if c.Regression(*threshold / 100) {
+			log.Printf("regression: %s is %.2f%% slower (p=%.3f)", c.Name, 100*c.Delta, c.P)
+			code = exitRegression
+		}
+	}
+	return code
+}
+
+// profile runs the profile subcommand, printing the opcode and Solidity
+// function profile of a single Benchmark call in the EVM.
+func profile(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	artifactsDir := fs.String("artifacts", "snailtracer/testdata", "directory holding the compiled contract and its source map")
+	source := fs.String("source", "sol/snailtracer.sol", "Solidity source of the contract, used with the source map")
+	seed := fs.Int("seed", 0, "seed passed to the benchmark")
+	jsonPath := fs.String("json", "", "write the JSON profile to this file, - for stdout")
+	fs.Parse(args)
+
+	artifacts, err := backends.LoadArtifacts(*artifactsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var srcmap *backends.SourceMap
+	if raw := artifacts[backends.EVMSourceMapArtifact]; len(raw) > 0 {
+		src, err := os.ReadFile(*source)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if srcmap, err = backends.NewSourceMap(string(raw), src); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Printf("no %s, skipping the per-function profile", backends.EVMSourceMapArtifact)
+	}
+	// Set up the backend like run does, with the creation code and ABI
+	b, err := backends.NewBackend("evm", artifacts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	backend, ok := b.(*backends.EVMBackend)
+	if !ok {
+		log.Fatalf("evm: unexpected backend %T", b)
+	}
+	if err := backend.Setup(); err != nil {
+		log.Fatal(err)
+	}
+	defer backend.Close()
+
+	prof, err := backend.Profile(*seed, srcmap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := prof.WriteTable(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	if err := write(*jsonPath, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(prof)
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readReport(path string) (*bench.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	report, err := bench.ReadReport(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
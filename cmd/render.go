@@ -18,10 +18,6 @@ import (
 )
 
 const (
-	originX  = 0
-	originY  = 0
-	width    = 1024
-	height   = 768
 	spp      = 5
 	filename = "out.png"
 )
@@ -35,25 +31,33 @@ type worker struct {
 	done   chan int
 }
 
-func vectorToColor(v snailtracer.Vector) color.Color {
-	return color.RGBA{R: byte(v.X.Uint64()), G: byte(v.Y.Uint64()), B: byte(v.Z.Uint64()), A: 255}
-}
-
 func (w *worker) render() {
 	for y := range w.lines {
 		fmt.Println("Starting worker", w.id, "rendering line", y)
-		for x := originX; x < originX+width; x++ {
-			select {
-			case <-w.ctx.Done():
-				w.done <- w.id
-				return
-			default:
-				v := vectorToColor(w.scene.Trace(x, y, spp))
-				w.canvas.set(x, y, v)
-			}
-		}
+		traced := w.renderLine(y)
 		w.done <- w.id
+		if !traced {
+			return
+		}
+	}
+}
+
+// renderLine traces scanline y pixel by pixel like Scene.TraceScanline,
+// checking for cancellation between pixels. A cancelled line is drawn as far as
+// it was traced and false is returned.
+func (w *worker) renderLine(y int) bool {
+	rgb := make([]byte, 0, 3*w.scene.Width())
+	defer func() { w.canvas.setLine(y, rgb) }()
+	for x := 0; x < w.scene.Width(); x++ {
+		select {
+		case <-w.ctx.Done():
+			return false
+		default:
+		}
+		r, g, b := w.scene.TracePixel(x, y, spp)
+		rgb = append(rgb, r, g, b)
 	}
+	return true
 }
 
 type canvas struct {
@@ -61,14 +65,21 @@ type canvas struct {
 	img  *image.RGBA
 }
 
-func (c *canvas) set(x, y int, v color.Color) {
+// setLine copies a traced scanline into the image, flipping it vertically as
+// scanlines are numbered bottom-up.
+func (c *canvas) setLine(y int, rgb []byte) {
 	c.lock.Lock()
-	c.img.Set(x-originX, height-(y-originY)-1, v)
-	c.lock.Unlock()
+	defer c.lock.Unlock()
+	height := c.img.Bounds().Dy()
+	for x := 0; x < len(rgb)/3; x++ {
+		c.img.Set(x, height-y-1, color.RGBA{R: rgb[3*x], G: rgb[3*x+1], B: rgb[3*x+2], A: 255})
+	}
 }
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
+	scene := snailtracer.NewBenchmarkScene(0, 0)
+	width, height := scene.Width(), scene.Height()
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	routines := runtime.NumCPU()
@@ -90,7 +101,7 @@ func main() {
 	imgCanvas := &canvas{img: img}
 
 	for i := 0; i < height; i++ {
-		lineChan <- (originY + i)
+		lineChan <- i
 	}
 	close(lineChan)
 
@@ -133,4 +144,11 @@ Loop:
 	if err := png.Encode(file, img); err != nil {
 		log.Fatalf("failed to encode: %s", err)
 	}
+
+	if snailtracer.Diagnostics {
+		fmt.Println("\nFixed-point diagnostics:")
+		if err := snailtracer.WriteDiagnostics(os.Stdout); err != nil {
+			log.Fatalf("failed to write diagnostics: %s", err)
+		}
+	}
 }
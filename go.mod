@@ -7,6 +7,7 @@ require (
 	github.com/holiman/uint256 v1.2.3
 	github.com/tetratelabs/wazero v1.5.0
 	github.com/wasmerio/wasmer-go v1.0.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
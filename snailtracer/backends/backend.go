@@ -0,0 +1,288 @@
+//go:build !tinygo && !wasip1
+
+// Package backends runs the snailtracer benchmark on the runtimes it compares:
+// the native Go tracer, the contract on the EVM and the TinyGo and wasip1
+// modules on Wasmer and wazero.
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/tetratelabs/wazero"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// Backend runs the benchmark workload, the contract's Benchmark method, on a
+// single runtime: the four hand picked pixels of the benchmark scene are traced
+// at 8 spp and their colors averaged.
+type Backend interface {
+	// Name identifies the backend, e.g. "wazero/compiler/o2".
+	Name() string
+	// Setup prepares the backend for running, e.g. by deploying the contract or
+	// instantiating the Wasm module. It must be called once before Run.
+	Setup() error
+	// Run executes the benchmark once with the given seed and returns the
+	// resulting color.
+	Run(seed int) (r, g, b byte, err error)
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+// ImageTracer is implemented by the backends that can trace any part of the
+// benchmark image, returning the same bytes as the snailtracer.Scene methods of
+// the same name.
+type ImageTracer interface {
+	TracePixel(x, y, spp int) (r, g, b byte, err error)
+	TraceScanline(y, spp int) ([]byte, error)
+	TraceImage(spp int) ([]byte, error)
+}
+
+// Scaler is implemented by the backends that can trace at another fixed-point
+// scale than the contract's 1e6, see snailtracer.Scale. The EVM backends
+// cannot: the scale is compiled into the contract.
+type Scaler interface {
+	// SetScale traces at the scale 1e<digits> from then on. It must be called
+	// after Setup. Run only produces a valid result at the 1e6 scale.
+	SetScale(digits int) error
+}
+
+// MathSetter is implemented by the backends that can trace with other square
+// root and trigonometric functions than the contract's, see
+// snailtracer.MathMode.
+type MathSetter interface {
+	// SetMath traces with the math mode from then on. It must be called after
+	// Setup.
+	SetMath(mode snailtracer.MathMode) error
+}
+
+// Artifact file names of the compiled contract and Wasm modules, as produced
+// by `make solidity`, `make tinygo` and `make wasip1`.
+const (
+	EVMArtifact          = "snailtracer.evm"
+	EVMCreationArtifact  = "snailtracer.bin"
+	EVMABIArtifact       = "snailtracer.abi"
+	EVMSourceMapArtifact = "snailtracer.srcmap"
+	WasmO2Artifact       = "snailtracer_o2.wasm"
+	WasmOzArtifact       = "snailtracer_oz.wasm"
+	WasmGoArtifact       = "snailtracer_go.wasm" // Standard Go, GOOS=wasip1
+)
+
+// ErrMissingArtifact is returned by Setup when the code a backend executes was
+// not built.
+var ErrMissingArtifact = errors.New("missing artifact")
+
+// Artifacts holds the compiled code executed by the non-native backends, keyed
+// by artifact file name.
+type Artifacts map[string][]byte
+
+// LoadArtifacts reads the artifacts found in dir. Missing artifacts are not an
+// error; the backends that need them fail on Setup with ErrMissingArtifact
+// instead.
+func LoadArtifacts(dir string) (Artifacts, error) {
+	artifacts := make(Artifacts)
+	for _, name := range []string{EVMArtifact, EVMCreationArtifact, EVMABIArtifact, EVMSourceMapArtifact, WasmO2Artifact, WasmOzArtifact, WasmGoArtifact} {
+		code, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		artifacts[name] = code
+	}
+	return artifacts, nil
+}
+
+// BackendFactory creates a backend with the given name from the artifacts.
+type BackendFactory func(name string, artifacts Artifacts) Backend
+
+type registeredBackend struct {
+	name    string
+	factory BackendFactory
+}
+
+var backends []registeredBackend
+
+// RegisterBackend makes a backend available under name. Registering the same
+// name twice panics.
+func RegisterBackend(name string, factory BackendFactory) {
+	for _, backend := range backends {
+		if backend.name == name {
+			panic(fmt.Sprintf("backend %q already registered", name))
+		}
+	}
+	backends = append(backends, registeredBackend{name, factory})
+}
+
+// BackendNames returns the names of all registered backends in registration
+// order.
+func BackendNames() []string {
+	names := make([]string, len(backends))
+	for i, backend := range backends {
+		names[i] = backend.name
+	}
+	return names
+}
+
+// NewBackend creates the backend registered under name. The backend still has
+// to be set up before it can be run.
+func NewBackend(name string, artifacts Artifacts) (Backend, error) {
+	for _, backend := range backends {
+		if backend.name == name {
+			return backend.factory(name, artifacts), nil
+		}
+	}
+	known := BackendNames()
+	sort.Strings(known)
+	return nil, fmt.Errorf("unknown backend %q, available: %v", name, known)
+}
+
+func init() {
+	RegisterBackend("native", func(name string, _ Artifacts) Backend {
+		return NewNativeBackend(name, 1)
+	})
+	RegisterBackend("native/parallel4", func(name string, _ Artifacts) Backend {
+		return NewNativeBackend(name, len(snailtracer.BenchmarkPixels))
+	})
+	RegisterBackend("evm", func(name string, artifacts Artifacts) Backend {
+		return newEVMBackend(name, artifacts, params.TestChainConfig)
+	})
+	for _, fork := range EVMForks {
+		chainConfig, err := ForkChainConfig(fork)
+		if err != nil {
+			panic(err)
+		}
+		RegisterBackend("evm/"+fork, func(name string, artifacts Artifacts) Backend {
+			return newEVMBackend(name, artifacts, chainConfig)
+		})
+	}
+	for _, opt := range []struct{ name, artifact string }{{"o2", WasmO2Artifact}, {"oz", WasmOzArtifact}} {
+		opt := opt
+		RegisterBackend("wasmer/singlepass/"+opt.name, func(name string, artifacts Artifacts) Backend {
+			return NewWasmerBackend(name, artifacts[opt.artifact], wasmer.NewConfig().UseSinglepassCompiler())
+		})
+		RegisterBackend("wasmer/cranelift/"+opt.name, func(name string, artifacts Artifacts) Backend {
+			return NewWasmerBackend(name, artifacts[opt.artifact], wasmer.NewConfig().UseCraneliftCompiler())
+		})
+		RegisterBackend("wazero/interpreter/"+opt.name, func(name string, artifacts Artifacts) Backend {
+			return NewWazeroBackend(name, artifacts[opt.artifact], wazero.NewRuntimeConfigInterpreter())
+		})
+		RegisterBackend("wazero/compiler/"+opt.name, func(name string, artifacts Artifacts) Backend {
+			return NewWazeroBackend(name, artifacts[opt.artifact], wazero.NewRuntimeConfigCompiler())
+		})
+	}
+	RegisterBackend("wazero/interpreter/go", func(name string, artifacts Artifacts) Backend {
+		return NewWazeroBackend(name, artifacts[WasmGoArtifact], wazero.NewRuntimeConfigInterpreter())
+	})
+	RegisterBackend("wazero/compiler/go", func(name string, artifacts Artifacts) Backend {
+		return NewWazeroBackend(name, artifacts[WasmGoArtifact], wazero.NewRuntimeConfigCompiler())
+	})
+}
+
+// newEVMBackend creates an EVM backend deploying the contract with its creation
+// bytecode and calling it through its ABI when those were built.
+func newEVMBackend(name string, artifacts Artifacts, chainConfig *params.ChainConfig) Backend {
+	backend := NewEVMBackendWithConfig(name, artifacts[EVMArtifact], chainConfig, vm.Config{})
+	if code := artifacts[EVMCreationArtifact]; len(code) > 0 {
+		backend.SetCreationCode(code)
+	}
+	if abiJSON := artifacts[EVMABIArtifact]; len(abiJSON) > 0 {
+		backend.SetABI(abiJSON)
+	}
+	return backend
+}
+
+// NativeBackend runs the benchmark with the Go tracer, spreading the benchmark
+// pixels over one goroutine and scene per worker.
+type NativeBackend struct {
+	name    string
+	workers int
+	scenes  []*snailtracer.Scene
+}
+
+// NewNativeBackend creates a native backend tracing on the given number of
+// workers; a single worker traces on the calling goroutine.
+func NewNativeBackend(name string, workers int) *NativeBackend {
+	return &NativeBackend{name: name, workers: workers}
+}
+
+func (n *NativeBackend) Name() string {
+	return n.name
+}
+
+func (n *NativeBackend) Setup() error {
+	if n.workers < 1 {
+		return fmt.Errorf("%s: invalid worker count %d", n.name, n.workers)
+	}
+	n.scenes = make([]*snailtracer.Scene, n.workers)
+	for i := range n.scenes {
+		n.scenes[i] = snailtracer.NewBenchmarkScene(0, 0)
+	}
+	return nil
+}
+
+// SetScale rescales the benchmark scene of every worker to 1e<digits>.
+func (n *NativeBackend) SetScale(digits int) error {
+	sc, err := snailtracer.NewScale(digits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", n.name, err)
+	}
+	for i, scene := range n.scenes {
+		s := snailtracer.NewBenchmarkScene(0, 0)
+		s.SetMath(scene.Math())
+		n.scenes[i] = s.Rescale(sc)
+	}
+	return nil
+}
+
+// SetMath sets the math mode of the scene of every worker.
+func (n *NativeBackend) SetMath(mode snailtracer.MathMode) error {
+	for _, scene := range n.scenes {
+		scene.SetMath(mode)
+	}
+	return nil
+}
+
+func (n *NativeBackend) Run(seed int) (r, g, b byte, err error) {
+	colors := make([]snailtracer.Vector, len(snailtracer.BenchmarkPixels))
+	trace := func(worker int) {
+		scene := n.scenes[worker]
+		for i := worker; i < len(snailtracer.BenchmarkPixels); i += len(n.scenes) {
+			p := snailtracer.BenchmarkPixels[i]
+			colors[i] = scene.Trace(p.X, p.Y, p.SPP)
+		}
+	}
+	if len(n.scenes) == 1 {
+		trace(0)
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(len(n.scenes))
+		for i := range n.scenes {
+			go func(worker int) {
+				defer wg.Done()
+				trace(worker)
+			}(i)
+		}
+		wg.Wait()
+	}
+	color := snailtracer.NewVector(0, 0, 0)
+	for _, c := range colors {
+		color = color.Add(c)
+	}
+	color = color.ScaleDiv(uint256.NewInt(uint64(len(colors))))
+	return byte(color.X.Uint64()), byte(color.Y.Uint64()), byte(color.Z.Uint64()), nil
+}
+
+func (n *NativeBackend) Close() error {
+	n.scenes = nil
+	return nil
+}
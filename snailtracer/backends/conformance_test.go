@@ -0,0 +1,154 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+var (
+	conformance       = flag.Bool("conformance", false, "compare native, EVM and Wasm output pixel by pixel")
+	conformancePixels = flag.String("conformance.pixels", "512:384,325:540,600:600,522:524", "pixels to compare as x:y pairs separated by commas, or grid:N for every Nth pixel of the image")
+	conformanceSPP    = flag.Int("conformance.spp", 8, "samples per pixel traced by every backend")
+)
+
+type pixel struct{ x, y int }
+
+// parsePixels parses the -conformance.pixels flag for an image of the given
+// size.
+func parsePixels(spec string, width, height int) ([]pixel, error) {
+	if strings.HasPrefix(spec, "grid:") {
+		step := strings.TrimPrefix(spec, "grid:")
+		n, err := strconv.Atoi(step)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid grid step %q", step)
+		}
+		var pixels []pixel
+		for y := height - 1; y >= 0; y -= n {
+			for x := 0; x < width; x += n {
+				pixels = append(pixels, pixel{x, y})
+			}
+		}
+		return pixels, nil
+	}
+	var pixels []pixel
+	for _, pair := range strings.Split(spec, ",") {
+		xs, ys, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		x, errX := strconv.Atoi(xs)
+		y, errY := strconv.Atoi(ys)
+		if !ok || errX != nil || errY != nil || x < 0 || x >= width || y < 0 || y >= height {
+			return nil, fmt.Errorf("invalid pixel %q", pair)
+		}
+		pixels = append(pixels, pixel{x, y})
+	}
+	return pixels, nil
+}
+
+// pixelTracer traces a single pixel of the benchmark scene on one backend.
+type pixelTracer struct {
+	name  string
+	trace func(x, y, spp int) [3]byte
+}
+
+// newBackendPixelTracer sets up the named backend and traces pixels with its
+// TracePixel method.
+func newBackendPixelTracer(t *testing.T, name string) pixelTracer {
+	backend := newTestBackend(t, name).(ImageTracer)
+	return pixelTracer{name, func(x, y, spp int) [3]byte {
+		r, g, b, err := backend.TracePixel(x, y, spp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return [3]byte{r, g, b}
+	}}
+}
+
+// TestConformance traces the same pixels natively, in the EVM and in Wasm, and
+// reports every pixel on which a backend disagrees with the native tracer. It
+// only runs with -conformance as tracing in the EVM is slow; select the pixels
+// with -conformance.pixels, e.g. -conformance.pixels=grid:64 for a 16x12 image.
+func TestConformance(t *testing.T) {
+	if !*conformance {
+		t.Skip("conformance suite disabled, enable with -conformance")
+	}
+	scene := snailtracer.NewBenchmarkScene(0, 0)
+	pixels, err := parsePixels(*conformancePixels, scene.Width(), scene.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	native := pixelTracer{"native", func(x, y, spp int) [3]byte {
+		r, g, b := scene.TracePixel(x, y, spp)
+		return [3]byte{r, g, b}
+	}}
+	backends := []pixelTracer{
+		newBackendPixelTracer(t, "evm"),
+		newBackendPixelTracer(t, "wazero/compiler/o2"),
+		newBackendPixelTracer(t, "wazero/compiler/oz"),
+		newBackendPixelTracer(t, "wazero/compiler/go"),
+		newBackendPixelTracer(t, "wasmer/cranelift/o2"),
+	}
+
+	mismatches := 0
+	for _, p := range pixels {
+		want := native.trace(p.x, p.y, *conformanceSPP)
+		report := []string{fmt.Sprintf("%s=%v", native.name, want)}
+		differs := false
+		for _, backend := range backends {
+			have := backend.trace(p.x, p.y, *conformanceSPP)
+			report = append(report, fmt.Sprintf("%s=%v", backend.name, have))
+			differs = differs || have != want
+		}
+		if differs {
+			mismatches++
+			t.Errorf("pixel (%d, %d): %s", p.x, p.y, strings.Join(report, " "))
+		}
+	}
+	t.Logf("%d of %d pixels differ at %d spp", mismatches, len(pixels), *conformanceSPP)
+}
+
+// TestConformanceScanline traces the middle scanline of the image natively and
+// inside Wasm, copying it out of the module's linear memory. Like
+// TestConformance it only runs with -conformance.
+func TestConformanceScanline(t *testing.T) {
+	if !*conformance {
+		t.Skip("conformance suite disabled, enable with -conformance")
+	}
+	scene := snailtracer.NewBenchmarkScene(0, 0)
+	y := scene.Height() / 2
+	want := scene.TraceScanline(y, *conformanceSPP)
+	for _, name := range []string{"wazero/compiler/o2", "wazero/compiler/go", "wasmer/cranelift/o2"} {
+		have, err := newTestBackend(t, name).(ImageTracer).TraceScanline(y, *conformanceSPP)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Errorf("%s: scanline %d differs from native", name, y)
+		}
+	}
+}
+
+func TestParsePixels(t *testing.T) {
+	pixels, err := parsePixels("grid:512", 1024, 768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pixel{{0, 767}, {512, 767}, {0, 255}, {512, 255}}
+	if fmt.Sprint(pixels) != fmt.Sprint(want) {
+		t.Errorf("have %v, want %v", pixels, want)
+	}
+	if pixels, err = parsePixels("1:2, 3:4", 1024, 768); err != nil || len(pixels) != 2 || pixels[1] != (pixel{3, 4}) {
+		t.Errorf("have %v (%v), want [{1 2} {3 4}]", pixels, err)
+	}
+	for _, spec := range []string{"grid:0", "1", "1:x", "1024:0", "0:-1"} {
+		if _, err := parsePixels(spec, 1024, 768); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
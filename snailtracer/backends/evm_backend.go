@@ -0,0 +1,428 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+// defaultABI is the ABI of sol/snailtracer.sol, used unless the ABI built
+// alongside the bytecode is set.
+//
+//go:embed snailtracer.abi.json
+var defaultABI []byte
+
+var (
+	evmAddress  = common.HexToAddress("0xc0ffee") // Address of injected runtime bytecode
+	evmOrigin   = common.HexToAddress("0xc0ffee0001")
+	evmGasLimit = uint64(1e9)
+)
+
+// GasReporter is implemented by backends that meter execution in gas.
+type GasReporter interface {
+	// GasUsed returns the gas used by the last Run.
+	GasUsed() uint64
+}
+
+// PixelGas is the gas used to trace a single pixel.
+type PixelGas struct {
+	X   int    `json:"x"`
+	Y   int    `json:"y"`
+	SPP int    `json:"spp"`
+	Gas uint64 `json:"gas"`
+}
+
+// PixelGasReporter is implemented by backends that can break the gas of the
+// benchmark down by pixel.
+type PixelGasReporter interface {
+	// PixelGas traces every benchmark pixel on its own and returns the gas
+	// each used.
+	PixelGas() ([]PixelGas, error)
+}
+
+// EVMForks are the hard forks the EVM backend can be configured for, oldest
+// first.
+var EVMForks = []string{"istanbul", "berlin", "london", "shanghai", "cancun"}
+
+// ForkChainConfig returns a chain config with every fork up to and including
+// the given one activated from genesis.
+func ForkChainConfig(fork string) (*params.ChainConfig, error) {
+	config := *params.TestChainConfig
+	switch fork {
+	case "istanbul":
+		config.BerlinBlock = nil
+		fallthrough
+	case "berlin":
+		config.LondonBlock = nil
+		config.ArrowGlacierBlock = nil
+		config.GrayGlacierBlock = nil
+	case "london":
+	case "cancun":
+		config.CancunTime = new(uint64)
+		fallthrough
+	case "shanghai":
+		config.ShanghaiTime = new(uint64)
+		config.TerminalTotalDifficulty = common.Big0
+		config.TerminalTotalDifficultyPassed = true
+	default:
+		return nil, fmt.Errorf("unknown fork %q, available: %v", fork, EVMForks)
+	}
+	return &config, nil
+}
+
+// EVMDeployment is the cost of deploying and initializing the contract,
+// measured separately from the benchmark calls.
+type EVMDeployment struct {
+	// Created is set when the contract was deployed with its creation bytecode
+	// rather than injected into the state.
+	Created      bool          `json:"created"`
+	IntrinsicGas uint64        `json:"intrinsic_gas,omitempty"` // Creation transaction base and calldata cost
+	Gas          uint64        `json:"gas,omitempty"`           // Creation code execution and code deposit cost
+	Time         time.Duration `json:"ns,omitempty"`
+	InitGas      uint64        `json:"init_gas"` // Cost of the Init call
+	InitTime     time.Duration `json:"init_ns"`
+}
+
+// DeploymentReporter is implemented by backends that deploy a contract on
+// Setup.
+type DeploymentReporter interface {
+	// Deployment describes how the contract was deployed by the last Setup.
+	Deployment() EVMDeployment
+}
+
+// EVMBackend runs the benchmark by calling the snailtracer contract's Benchmark
+// method in go-ethereum's EVM. Every call is executed like a transaction of
+// its own, on the state committed by the previous one and with a fresh access
+// list.
+type EVMBackend struct {
+	name         string
+	bytecode     []byte
+	creationCode []byte
+	abiJSON      []byte
+	abi          abi.ABI
+	chainConfig  *params.ChainConfig
+	vmConfig     vm.Config
+	evm          *vm.EVM
+	statedb      *state.StateDB
+	rules        params.Rules
+	address      common.Address
+	deployment   EVMDeployment
+	gasUsed      uint64
+
+	// benchmarkInput is the ABI encoded Benchmark call for benchmarkSeed,
+	// encoded outside of the timed runs.
+	benchmarkInput []byte
+	benchmarkSeed  int
+}
+
+// NewEVMBackend creates an EVM backend for the hex encoded runtime bytecode of
+// the snailtracer contract, executed under params.TestChainConfig.
+func NewEVMBackend(name string, bytecodeHex []byte) *EVMBackend {
+	return NewEVMBackendWithConfig(name, bytecodeHex, params.TestChainConfig, vm.Config{})
+}
+
+// NewEVMBackendWithConfig creates an EVM backend executing the contract under
+// the given chain and EVM configuration.
+func NewEVMBackendWithConfig(name string, bytecodeHex []byte, chainConfig *params.ChainConfig, vmConfig vm.Config) *EVMBackend {
+	return &EVMBackend{name: name, bytecode: bytecodeHex, chainConfig: chainConfig, vmConfig: vmConfig}
+}
+
+// SetCreationCode makes Setup deploy the contract with its hex encoded
+// creation bytecode. It has to be called before Setup.
+func (e *EVMBackend) SetCreationCode(creationCodeHex []byte) {
+	e.creationCode = creationCodeHex
+}
+
+// SetABI makes the backend encode its calls and decode their results with the
+// contract's ABI JSON instead of the ABI the package was written against. It
+// has to be called before Setup.
+func (e *EVMBackend) SetABI(abiJSON []byte) {
+	e.abiJSON = abiJSON
+}
+
+// SetExtraEIPs enables additional EIPs on top of the chain config's fork. It
+// has to be called before Setup.
+func (e *EVMBackend) SetExtraEIPs(eips []int) {
+	e.vmConfig.ExtraEips = eips
+}
+
+func (e *EVMBackend) Name() string {
+	return e.name
+}
+
+// Setup deploys the contract into a fresh in-memory state and initializes its
+// scene. With creation bytecode the contract is deployed through its
+// constructor and the deployed code checked against the runtime bytecode, if
+// any; otherwise the runtime bytecode is injected directly.
+func (e *EVMBackend) Setup() error {
+	if len(e.bytecode) == 0 && len(e.creationCode) == 0 {
+		return fmt.Errorf("%s: %w: %s", e.name, ErrMissingArtifact, EVMArtifact)
+	}
+	for _, eip := range e.vmConfig.ExtraEips {
+		if !vm.ValidEip(eip) {
+			return fmt.Errorf("%s: unsupported EIP %d", e.name, eip)
+		}
+	}
+	abiJSON := e.abiJSON
+	if len(abiJSON) == 0 {
+		abiJSON = defaultABI
+	}
+	contractABI, err := abi.JSON(bytes.NewReader(abiJSON))
+	if err != nil {
+		return fmt.Errorf("%s: invalid ABI: %w", e.name, err)
+	}
+	e.abi = contractABI
+	if err := e.packBenchmark(0); err != nil {
+		return fmt.Errorf("%s: %w", e.name, err)
+	}
+
+	var (
+		bytecode  = common.FromHex(strings.TrimSpace(string(e.bytecode)))
+		txContext = vm.TxContext{
+			Origin:   evmOrigin,
+			GasPrice: common.Big1,
+		}
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			Coinbase:    common.Address{},
+			BlockNumber: common.Big1,
+			Time:        1,
+			Difficulty:  common.Big1,
+			GasLimit:    uint64(1e8),
+		}
+	)
+	if e.chainConfig.IsLondon(context.BlockNumber) {
+		context.BaseFee = common.Big1
+	}
+	if e.chainConfig.IsShanghai(context.BlockNumber, context.Time) {
+		// Post-merge forks are only active with PREVRANDAO set.
+		context.Random = &common.Hash{}
+	}
+
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return err
+	}
+
+	statedb.CreateAccount(evmOrigin)
+	statedb.SetBalance(evmOrigin, big.NewInt(1e18))
+
+	e.evm = vm.NewEVM(context, txContext, statedb, e.chainConfig, e.vmConfig)
+	e.statedb = statedb
+	e.rules = e.chainConfig.Rules(context.BlockNumber, context.Random != nil, context.Time)
+
+	if len(e.creationCode) > 0 {
+		if err := e.deploy(bytecode); err != nil {
+			return err
+		}
+	} else {
+		e.address = evmAddress
+		e.deployment = EVMDeployment{}
+		statedb.CreateAccount(evmAddress)
+		statedb.SetCode(evmAddress, bytecode)
+	}
+
+	start := time.Now()
+	_, gas, err := e.transact("Init")
+	e.deployment.InitGas, e.deployment.InitTime = gas, time.Since(start)
+	return err
+}
+
+// deploy runs the creation bytecode like a contract creation transaction. If
+// runtime is not empty the deployed code has to match it.
+func (e *EVMBackend) deploy(runtime []byte) error {
+	code := common.FromHex(strings.TrimSpace(string(e.creationCode)))
+	intrinsic, err := core.IntrinsicGas(code, nil, true, e.rules.IsHomestead, e.rules.IsIstanbul, e.rules.IsShanghai)
+	if err != nil {
+		return err
+	}
+	e.statedb.Finalise(true)
+	e.statedb.Prepare(e.rules, evmOrigin, e.evm.Context.Coinbase, nil, vm.ActivePrecompiles(e.rules), nil)
+
+	start := time.Now()
+	deployed, address, leftOver, err := e.evm.Create(vm.AccountRef(evmOrigin), code, evmGasLimit, common.Big0)
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("%s: deployment failed: %w", e.name, err)
+	}
+	if len(runtime) > 0 && !bytes.Equal(deployed, runtime) {
+		return fmt.Errorf("%s: deployed code does not match %s", e.name, EVMArtifact)
+	}
+	e.address = address
+	e.deployment = EVMDeployment{
+		Created:      true,
+		IntrinsicGas: intrinsic,
+		Gas:          evmGasLimit - leftOver,
+		Time:         elapsed,
+	}
+	return nil
+}
+
+// Deployment describes how the contract was deployed by the last Setup.
+func (e *EVMBackend) Deployment() EVMDeployment {
+	return e.deployment
+}
+
+// Run calls the contract's Benchmark method. The call is encoded by Setup for
+// seed 0 and again only when the seed changes.
+func (e *EVMBackend) Run(seed int) (r, g, b byte, err error) {
+	if seed != e.benchmarkSeed {
+		if err := e.packBenchmark(seed); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	out, gas, err := e.transactPacked("Benchmark", e.benchmarkInput)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	e.gasUsed = gas
+	return unpackColor(out)
+}
+
+// packBenchmark encodes the Benchmark call for seed.
+func (e *EVMBackend) packBenchmark(seed int) error {
+	input, err := e.abi.Pack("Benchmark", uint32(seed))
+	if err != nil {
+		return fmt.Errorf("Benchmark: %w", err)
+	}
+	e.benchmarkInput, e.benchmarkSeed = input, seed
+	return nil
+}
+
+// GasUsed returns the gas used by the last Run.
+func (e *EVMBackend) GasUsed() uint64 {
+	return e.gasUsed
+}
+
+// PixelGas traces every pixel of the benchmark individually with the
+// contract's TracePixel method and returns the gas each call used.
+func (e *EVMBackend) PixelGas() ([]PixelGas, error) {
+	pixels := make([]PixelGas, len(snailtracer.BenchmarkPixels))
+	for i, p := range snailtracer.BenchmarkPixels {
+		_, _, _, gas, err := e.tracePixel(p.X, p.Y, p.SPP)
+		if err != nil {
+			return nil, err
+		}
+		pixels[i] = PixelGas{X: p.X, Y: p.Y, SPP: p.SPP, Gas: gas}
+	}
+	return pixels, nil
+}
+
+// TracePixel calls the contract's TracePixel method.
+func (e *EVMBackend) TracePixel(x, y, spp int) (r, g, b byte, err error) {
+	r, g, b, _, err = e.tracePixel(x, y, spp)
+	return r, g, b, err
+}
+
+func (e *EVMBackend) tracePixel(x, y, spp int) (r, g, b byte, gas uint64, err error) {
+	out, gas, err := e.transact("TracePixel", big.NewInt(int64(x)), big.NewInt(int64(y)), big.NewInt(int64(spp)))
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	r, g, b, err = unpackColor(out)
+	return r, g, b, gas, err
+}
+
+// TraceScanline calls the contract's TraceScanline method. The contract
+// appends to its buffer, so the result includes all scanlines and images
+// traced before.
+func (e *EVMBackend) TraceScanline(y, spp int) ([]byte, error) {
+	out, _, err := e.transact("TraceScanline", big.NewInt(int64(y)), big.NewInt(int64(spp)))
+	if err != nil {
+		return nil, err
+	}
+	return unpackBytes(out)
+}
+
+// TraceImage calls the contract's TraceImage method. Like TraceScanline, the
+// result includes everything traced before.
+func (e *EVMBackend) TraceImage(spp int) ([]byte, error) {
+	out, _, err := e.transact("TraceImage", big.NewInt(int64(spp)))
+	if err != nil {
+		return nil, err
+	}
+	return unpackBytes(out)
+}
+
+// transact calls the contract's method with the ABI encoded arguments and
+// returns the decoded outputs and the gas used.
+func (e *EVMBackend) transact(method string, args ...interface{}) ([]interface{}, uint64, error) {
+	input, err := e.abi.Pack(method, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", method, err)
+	}
+	return e.transactPacked(method, input)
+}
+
+// transactPacked is transact for an input already ABI encoded, decoding the
+// outputs once the call has returned.
+func (e *EVMBackend) transactPacked(method string, input []byte) ([]interface{}, uint64, error) {
+	ret, gas, err := e.call(input)
+	if err != nil {
+		return nil, gas, fmt.Errorf("%s: %w", method, err)
+	}
+	out, err := e.abi.Unpack(method, ret)
+	if err != nil {
+		return nil, gas, fmt.Errorf("%s: %w", method, err)
+	}
+	return out, gas, nil
+}
+
+// call calls the deployed contract with the given input and returns its return
+// data and the gas used. As in a transaction, the changes of the previous call
+// are committed, so storage writes are priced against the values it left, and
+// the access list is reset to the origin, the contract and the precompiles.
+func (e *EVMBackend) call(input []byte) ([]byte, uint64, error) {
+	e.statedb.Finalise(true)
+	e.statedb.Prepare(e.rules, evmOrigin, e.evm.Context.Coinbase, &e.address, vm.ActivePrecompiles(e.rules), nil)
+	ret, leftOver, err := e.evm.Call(vm.AccountRef(evmOrigin), e.address, input, evmGasLimit, common.Big0)
+	return ret, evmGasLimit - leftOver, err
+}
+
+// unpackColor converts the decoded (bytes1, bytes1, bytes1) color outputs.
+func unpackColor(out []interface{}) (r, g, b byte, err error) {
+	if len(out) != 3 {
+		return 0, 0, 0, fmt.Errorf("have %d outputs, want a color", len(out))
+	}
+	var color [3]byte
+	for i := range color {
+		c, ok := out[i].([1]byte)
+		if !ok {
+			return 0, 0, 0, fmt.Errorf("unexpected color output %T", out[i])
+		}
+		color[i] = c[0]
+	}
+	return color[0], color[1], color[2], nil
+}
+
+// unpackBytes converts the decoded bytes output.
+func unpackBytes(out []interface{}) ([]byte, error) {
+	if len(out) != 1 {
+		return nil, fmt.Errorf("have %d outputs, want bytes", len(out))
+	}
+	rgb, ok := out[0].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected output %T", out[0])
+	}
+	return rgb, nil
+}
+
+func (e *EVMBackend) Close() error {
+	e.evm, e.statedb = nil, nil
+	return nil
+}
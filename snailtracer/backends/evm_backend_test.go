@@ -0,0 +1,209 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+func TestEVMForks(t *testing.T) {
+	// PUSH1 0 SLOAD POP PUSH1 0 SLOAD POP STOP: a cold and a warm load of the
+	// same slot from EIP-2929 on.
+	code := []byte("0x60005450600054500000")
+	tests := []struct {
+		fork string
+		gas  uint64
+	}{
+		{"istanbul", 3 + 800 + 2 + 3 + 800 + 2},
+		{"berlin", 3 + 2100 + 2 + 3 + 100 + 2},
+		{"london", 3 + 2100 + 2 + 3 + 100 + 2},
+		{"shanghai", 3 + 2100 + 2 + 3 + 100 + 2},
+		{"cancun", 3 + 2100 + 2 + 3 + 100 + 2},
+	}
+	for _, tt := range tests {
+		chainConfig, err := ForkChainConfig(tt.fork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend := NewEVMBackendWithConfig(tt.fork, code, chainConfig, vm.Config{})
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", tt.fork, err)
+		}
+		// The second call must pay for the cold access again.
+		for i := 0; i < 2; i++ {
+			_, gas, err := backend.call(nil)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.fork, err)
+			}
+			if gas != tt.gas {
+				t.Errorf("%s: call %d: have gas %d, want %d", tt.fork, i, gas, tt.gas)
+			}
+		}
+	}
+	if _, err := ForkChainConfig("frontier"); err == nil {
+		t.Error("expected error for unknown fork")
+	}
+}
+
+// TestEVMCommits checks that consecutive Benchmark calls are priced like
+// transactions of their own, with the storage written by the previous call
+// committed.
+func TestEVMCommits(t *testing.T) {
+	// PUSH1 4 CALLDATALOAD PUSH1 0 SSTORE STOP: store the seed argument in
+	// slot 0, like Benchmark does.
+	code := []byte("0x60043560005500")
+	tests := []struct {
+		fork        string
+		first, next uint64
+	}{
+		// Setting a zero slot, then resetting a committed nonzero one.
+		{"istanbul", 3 + 3 + 3 + 20000, 3 + 3 + 3 + 5000},
+		{"berlin", 3 + 3 + 3 + 2100 + 20000, 3 + 3 + 3 + 2100 + 2900},
+		{"cancun", 3 + 3 + 3 + 2100 + 20000, 3 + 3 + 3 + 2100 + 2900},
+	}
+	for _, tt := range tests {
+		chainConfig, err := ForkChainConfig(tt.fork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend := NewEVMBackendWithConfig(tt.fork, code, chainConfig, vm.Config{})
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", tt.fork, err)
+		}
+		for seed := 1; seed <= 3; seed++ {
+			if err := backend.packBenchmark(seed); err != nil {
+				t.Fatal(err)
+			}
+			_, gas, err := backend.call(backend.benchmarkInput)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.fork, err)
+			}
+			want := tt.next
+			if seed == 1 {
+				want = tt.first
+			}
+			if gas != want {
+				t.Errorf("%s: seed %d: have gas %d, want %d", tt.fork, seed, gas, want)
+			}
+		}
+	}
+}
+
+func TestEVMPush0(t *testing.T) {
+	// PUSH0 POP STOP is only valid from Shanghai on, or with EIP-3855.
+	code := []byte("0x5f5000")
+	for _, fork := range EVMForks {
+		chainConfig, _ := ForkChainConfig(fork)
+		err := NewEVMBackendWithConfig(fork, code, chainConfig, vm.Config{}).Setup()
+		if valid := fork == "shanghai" || fork == "cancun"; valid != (err == nil) {
+			t.Errorf("%s: unexpected error %v", fork, err)
+		}
+	}
+	chainConfig, _ := ForkChainConfig("london")
+	backend := NewEVMBackendWithConfig("london", code, chainConfig, vm.Config{})
+	backend.SetExtraEIPs([]int{3855})
+	if err := backend.Setup(); err != nil {
+		t.Errorf("london with EIP-3855: %v", err)
+	}
+	backend.SetExtraEIPs([]int{1})
+	if err := backend.Setup(); err == nil {
+		t.Error("expected error for unsupported EIP")
+	}
+}
+
+func TestEVMDeploy(t *testing.T) {
+	// Creation code returning the runtime code of TestEVMForks.
+	runtime := "60005450600054500000"
+	creation := []byte("0x600a600c600039600a6000f3" + runtime)
+
+	backend := NewEVMBackend("evm", []byte("0x"+runtime))
+	backend.SetCreationCode(creation)
+	if err := backend.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	d := backend.Deployment()
+	// Five pushes, a one word CODECOPY with its memory expansion and RETURN,
+	// plus the deposit of 10 bytes of code. The creation code has 16 non-zero
+	// and 6 zero bytes.
+	if !d.Created || d.Gas != 5*3+(3+3+3)+0+10*200 || d.IntrinsicGas != 53000+16*16+6*4 || d.InitGas == 0 {
+		t.Errorf("unexpected deployment %+v", d)
+	}
+	if backend.address == evmAddress {
+		t.Error("contract was not deployed to a fresh address")
+	}
+	if _, gas, err := backend.call(nil); err != nil || gas != 3+2100+2+3+100+2 {
+		t.Errorf("have gas %d (%v), want %d", gas, err, 3+2100+2+3+100+2)
+	}
+
+	mismatch := NewEVMBackend("evm", []byte("0x00"))
+	mismatch.SetCreationCode(creation)
+	if err := mismatch.Setup(); err == nil {
+		t.Error("expected error for mismatching runtime code")
+	}
+
+	injected := NewEVMBackend("evm", []byte("0x"+runtime))
+	if err := injected.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if d := injected.Deployment(); d.Created || d.Gas != 0 || d.InitGas == 0 {
+		t.Errorf("unexpected deployment %+v", d)
+	}
+}
+
+func TestEVMABI(t *testing.T) {
+	contractABI, err := abi.JSON(bytes.NewReader(defaultABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for method, selector := range map[string]string{
+		"Init":          "57a86f7d",
+		"Benchmark":     "351578bc",
+		"TracePixel":    hex.EncodeToString(crypto.Keccak256([]byte("TracePixel(int256,int256,int256)"))[:4]),
+		"TraceScanline": hex.EncodeToString(crypto.Keccak256([]byte("TraceScanline(int256,int256)"))[:4]),
+		"TraceImage":    hex.EncodeToString(crypto.Keccak256([]byte("TraceImage(int256)"))[:4]),
+	} {
+		if have := hex.EncodeToString(contractABI.Methods[method].ID); have != selector {
+			t.Errorf("%s: have selector %s, want %s", method, have, selector)
+		}
+	}
+
+	// Returns the benchmark color (0x11, 0x11, 0x35) ABI encoded as three
+	// bytes1 values, whatever the call.
+	code := []byte("0x60116000536011602053603560405360606000f3")
+	backend := NewEVMBackend("evm", code)
+	if err := backend.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, err := backend.Run(0); err != nil || !snailtracer.ValidBenchmarkResult(r, g, b) {
+		t.Errorf("have %d %d %d (%v), want the benchmark color", r, g, b, err)
+	}
+	if r, g, b, err := backend.TracePixel(1, 2, 3); err != nil || !snailtracer.ValidBenchmarkResult(r, g, b) {
+		t.Errorf("have %d %d %d (%v), want the benchmark color", r, g, b, err)
+	}
+	// The Benchmark call is encoded once per seed
+	if _, _, _, err := backend.Run(7); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := hex.EncodeToString(backend.benchmarkInput), "351578bc"+strings.Repeat("0", 63)+"7"; have != want {
+		t.Errorf("have Benchmark input %s, want %s", have, want)
+	}
+
+	// An ABI out of sync with the contract fails instead of decoding garbage.
+	changed := bytes.Replace(defaultABI, []byte(`"name": "b", "type": "bytes1"`), []byte(`"name": "b", "type": "bytes"`), -1)
+	backend = NewEVMBackend("evm", code)
+	backend.SetABI(changed)
+	if err := backend.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := backend.Run(0); err == nil {
+		t.Error("expected error for mismatching ABI")
+	}
+}
@@ -0,0 +1,384 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// OpcodeProfile is the execution profile of a single opcode.
+type OpcodeProfile struct {
+	Op    string        `json:"op"`
+	Count uint64        `json:"count"`
+	Gas   uint64        `json:"gas"`
+	Time  time.Duration `json:"ns"`
+}
+
+// FunctionProfile is the execution profile of the instructions the source map
+// attributes to a Solidity function.
+type FunctionProfile struct {
+	Name  string        `json:"name"`
+	Count uint64        `json:"count"`
+	Gas   uint64        `json:"gas"`
+	Time  time.Duration `json:"ns"`
+}
+
+// EVMProfile is the execution profile of a Benchmark call. Opcodes and
+// functions are sorted by gas, descending.
+type EVMProfile struct {
+	GasUsed   uint64            `json:"gas_used"`
+	Time      time.Duration     `json:"ns"`
+	Opcodes   []OpcodeProfile   `json:"opcodes"`
+	Functions []FunctionProfile `json:"functions,omitempty"`
+}
+
+// Profile calls the contract's Benchmark method once with an opcode tracer and
+// returns its profile. Opcode times include the tracer's overhead, so they are
+// only meaningful relative to each other. If srcmap is not nil the profile is
+// also broken down by Solidity function.
+func (e *EVMBackend) Profile(seed int, srcmap *SourceMap) (*EVMProfile, error) {
+	if e.evm == nil {
+		return nil, fmt.Errorf("%s: not set up", e.name)
+	}
+	p := &evmProfiler{}
+	if srcmap != nil {
+		code := e.evm.StateDB.GetCode(e.address)
+		p.functions = srcmap.functions
+		p.pcFunction = srcmap.pcFunctions(code)
+		p.funcs = make([]FunctionProfile, len(srcmap.functions)+1)
+	}
+	e.evm.Config.Tracer = p
+	defer func() { e.evm.Config.Tracer = nil }()
+
+	input, err := e.abi.Pack("Benchmark", uint32(seed))
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	_, gas, err := e.call(input)
+	elapsed := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	return p.profile(gas, elapsed), nil
+}
+
+// evmProfiler is a vm.EVMLogger accumulating per-opcode and per-function
+// counts, gas and time. The time between two steps is attributed to the
+// former.
+type evmProfiler struct {
+	ops [256]OpcodeProfile
+
+	functions  []solidityFunction
+	pcFunction []int // Index into funcs by program counter
+	funcs      []FunctionProfile
+
+	last     time.Time
+	lastOp   vm.OpCode
+	lastFunc int
+	stepping bool
+}
+
+func (p *evmProfiler) CaptureTxStart(gasLimit uint64) {}
+
+func (p *evmProfiler) CaptureTxEnd(restGas uint64) {}
+
+func (p *evmProfiler) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+
+func (p *evmProfiler) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	p.flush(time.Now())
+}
+
+func (p *evmProfiler) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (p *evmProfiler) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (p *evmProfiler) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	now := time.Now()
+	p.flush(now)
+
+	p.ops[op].Count++
+	p.ops[op].Gas += cost
+	p.last, p.lastOp, p.stepping = now, op, true
+
+	if p.funcs != nil {
+		fn := len(p.funcs) - 1 // Unattributed
+		if pc < uint64(len(p.pcFunction)) && p.pcFunction[pc] >= 0 {
+			fn = p.pcFunction[pc]
+		}
+		p.funcs[fn].Count++
+		p.funcs[fn].Gas += cost
+		p.lastFunc = fn
+	}
+}
+
+func (p *evmProfiler) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// flush attributes the time since the last step to its opcode and function.
+func (p *evmProfiler) flush(now time.Time) {
+	if !p.stepping {
+		return
+	}
+	elapsed := now.Sub(p.last)
+	p.ops[p.lastOp].Time += elapsed
+	if p.funcs != nil {
+		p.funcs[p.lastFunc].Time += elapsed
+	}
+	p.stepping = false
+}
+
+func (p *evmProfiler) profile(gasUsed uint64, elapsed time.Duration) *EVMProfile {
+	profile := &EVMProfile{GasUsed: gasUsed, Time: elapsed}
+	for op, prof := range p.ops {
+		if prof.Count > 0 {
+			prof.Op = vm.OpCode(op).String()
+			profile.Opcodes = append(profile.Opcodes, prof)
+		}
+	}
+	sort.SliceStable(profile.Opcodes, func(i, j int) bool { return profile.Opcodes[i].Gas > profile.Opcodes[j].Gas })
+
+	for i, prof := range p.funcs {
+		if prof.Count == 0 {
+			continue
+		}
+		if i < len(p.functions) {
+			prof.Name = p.functions[i].String()
+		} else {
+			prof.Name = "(unattributed)"
+		}
+		profile.Functions = append(profile.Functions, prof)
+	}
+	sort.SliceStable(profile.Functions, func(i, j int) bool { return profile.Functions[i].Gas > profile.Functions[j].Gas })
+	return profile
+}
+
+// WriteTable writes the profile as human-readable tables, with the share of the
+// total gas and traced time of every opcode and function.
+func (p *EVMProfile) WriteTable(w io.Writer) error {
+	var total time.Duration
+	for _, op := range p.Opcodes {
+		total += op.Time
+	}
+	percent := func(part, whole float64) string {
+		if whole == 0 {
+			return "-"
+		}
+		return strconv.FormatFloat(100*part/whole, 'f', 2, 64) + "%"
+	}
+	if _, err := fmt.Fprintf(w, "gas used: %d, time: %s\n\n", p.GasUsed, p.Time); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "opcode\tcount\tgas\tgas%\ttime\ttime%\t")
+	for _, op := range p.Opcodes {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t\n", op.Op, op.Count, op.Gas,
+			percent(float64(op.Gas), float64(p.GasUsed)), op.Time, percent(float64(op.Time), float64(total)))
+	}
+	if len(p.Functions) > 0 {
+		fmt.Fprintln(tw, "\t\t\t\t\t\t")
+		fmt.Fprintln(tw, "function\tinstructions\tgas\tgas%\ttime\ttime%\t")
+		for _, fn := range p.Functions {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t\n", fn.Name, fn.Count, fn.Gas,
+				percent(float64(fn.Gas), float64(p.GasUsed)), fn.Time, percent(float64(fn.Time), float64(total)))
+		}
+	}
+	return tw.Flush()
+}
+
+// SourceMap maps the instructions of the contract's runtime bytecode to the
+// Solidity functions they were compiled from.
+type SourceMap struct {
+	entries   []sourceMapEntry
+	functions []solidityFunction
+}
+
+type sourceMapEntry struct {
+	start, length, file int
+}
+
+type solidityFunction struct {
+	name       string
+	line       int
+	start, end int // Byte range of the declaration and body
+}
+
+// String names the function together with its line, as Solidity functions are
+// often overloaded.
+func (f solidityFunction) String() string {
+	return fmt.Sprintf("%s:%d", f.name, f.line)
+}
+
+// NewSourceMap parses the compressed runtime source map emitted by solc (the
+// deployedBytecode.sourceMap field of the artifact) against the contract's
+// source. Only locations in source file 0 are attributed to functions.
+func NewSourceMap(srcmap string, source []byte) (*SourceMap, error) {
+	var (
+		entries []sourceMapEntry
+		prev    = sourceMapEntry{-1, -1, -1}
+	)
+	for i, item := range strings.Split(strings.TrimSpace(srcmap), ";") {
+		entry := prev
+		fields := strings.Split(item, ":")
+		for j, dst := range []*int{&entry.start, &entry.length, &entry.file} {
+			if j >= len(fields) || fields[j] == "" {
+				continue
+			}
+			n, err := strconv.Atoi(fields[j])
+			if err != nil {
+				return nil, fmt.Errorf("source map entry %d: %v", i, err)
+			}
+			*dst = n
+		}
+		entries = append(entries, entry)
+		prev = entry
+	}
+	return &SourceMap{entries: entries, functions: parseSolidityFunctions(source)}, nil
+}
+
+// function returns the index of the function containing the instruction with
+// the given index, or -1.
+func (m *SourceMap) function(instruction int) int {
+	if instruction >= len(m.entries) {
+		return -1
+	}
+	entry := m.entries[instruction]
+	if entry.file != 0 {
+		return -1
+	}
+	for i, fn := range m.functions {
+		if fn.start <= entry.start && entry.start+entry.length <= fn.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// pcFunctions returns the function index of every program counter in code, -1
+// where there is none. Source map entries are per instruction, so PUSH data
+// has to be skipped.
+func (m *SourceMap) pcFunctions(code []byte) []int {
+	pcs := make([]int, len(code))
+	for i := range pcs {
+		pcs[i] = -1
+	}
+	for pc, instruction := 0, 0; pc < len(code); instruction++ {
+		pcs[pc] = m.function(instruction)
+		op := vm.OpCode(code[pc])
+		pc++
+		if op.IsPush() {
+			pc += int(op - vm.PUSH0)
+		}
+	}
+	return pcs
+}
+
+// parseSolidityFunctions finds the functions declared in a Solidity source by
+// matching the braces of their bodies, skipping comments and string literals.
+func parseSolidityFunctions(source []byte) []solidityFunction {
+	var (
+		functions []solidityFunction
+		src       = string(source)
+		code      = maskComments(src)
+	)
+	for offset := 0; ; {
+		idx := strings.Index(code[offset:], "function")
+		if idx < 0 {
+			break
+		}
+		start := offset + idx
+		offset = start + len("function")
+		if start > 0 && isIdentByte(code[start-1]) || offset < len(code) && isIdentByte(code[offset]) {
+			continue
+		}
+		nameStart := offset
+		for nameStart < len(code) && (code[nameStart] == ' ' || code[nameStart] == '\t' || code[nameStart] == '\n' || code[nameStart] == '\r') {
+			nameStart++
+		}
+		nameEnd := nameStart
+		for nameEnd < len(code) && isIdentByte(code[nameEnd]) {
+			nameEnd++
+		}
+		body := strings.IndexAny(code[nameEnd:], "{;")
+		if nameEnd == nameStart || body < 0 || code[nameEnd+body] == ';' {
+			continue // Function type or declaration without a body
+		}
+		depth, end := 0, -1
+		for i := nameEnd + body; i < len(code) && end < 0; i++ {
+			switch code[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		functions = append(functions, solidityFunction{
+			name:  code[nameStart:nameEnd],
+			line:  strings.Count(src[:start], "\n") + 1,
+			start: start,
+			end:   end,
+		})
+		offset = end
+	}
+	return functions
+}
+
+// maskComments replaces comments and string literals with spaces, keeping the
+// byte offsets of everything else intact.
+func maskComments(src string) string {
+	masked := []byte(src)
+	for i := 0; i < len(masked); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			for ; i < len(masked) && masked[i] != '\n'; i++ {
+				masked[i] = ' '
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			for ; i < end && i < len(masked); i++ {
+				if masked[i] != '\n' {
+					masked[i] = ' '
+				}
+			}
+			i--
+		case masked[i] == '"' || masked[i] == '\'':
+			quote := masked[i]
+			for i++; i < len(masked) && masked[i] != quote && masked[i] != '\n'; i++ {
+				if masked[i] == '\\' {
+					masked[i] = ' '
+					i++
+				}
+				if i < len(masked) {
+					masked[i] = ' '
+				}
+			}
+		}
+	}
+	return string(masked)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
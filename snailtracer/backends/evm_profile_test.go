@@ -0,0 +1,67 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSolidityFunctions(t *testing.T) {
+	src, err := os.ReadFile("../../sol/snailtracer.sol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(map[string]bool)
+	for _, fn := range parseSolidityFunctions(src) {
+		lines[fn.String()] = true
+	}
+	for _, want := range []string{"Init:21", "TracePixel:266", "Benchmark:308", "radiance:614", "radiance:670", "traceray:838"} {
+		if !lines[want] {
+			t.Errorf("missing function %s", want)
+		}
+	}
+
+	src = []byte("contract C {\n  // function a() { \n  string s = \"function b() {\";\n  function c(uint x) public { if (x > 0) { x; } }\n}\n")
+	functions := parseSolidityFunctions(src)
+	if len(functions) != 1 || functions[0].String() != "c:4" || string(src[functions[0].end-1]) != "}" {
+		t.Errorf("unexpected functions %+v", functions)
+	}
+}
+
+func TestEVMProfile(t *testing.T) {
+	// PUSH1 1 PUSH1 2 ADD POP STOP, with the first three instructions mapped
+	// into f and the rest outside of any function.
+	source := []byte("contract C {\n    function f() public {\n        1 + 2;\n    }\n}\n")
+	srcmap, err := NewSourceMap("20:5:0;;;0:10:0;", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := NewEVMBackend("evm", []byte("0x60016002015000"))
+	if _, err := backend.Profile(0, srcmap); err == nil {
+		t.Error("expected error before Setup")
+	}
+	if err := backend.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := backend.Profile(0, srcmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := make(map[string]OpcodeProfile)
+	for _, op := range profile.Opcodes {
+		ops[op.Op] = op
+	}
+	if len(ops) != 4 || ops["PUSH1"].Count != 2 || ops["PUSH1"].Gas != 6 || ops["ADD"].Count != 1 || ops["POP"].Gas != 2 || ops["STOP"].Count != 1 {
+		t.Errorf("unexpected opcode profile %+v", profile.Opcodes)
+	}
+	if len(profile.Functions) != 2 {
+		t.Fatalf("unexpected function profile %+v", profile.Functions)
+	}
+	if fn := profile.Functions[0]; fn.Name != "f:2" || fn.Count != 3 || fn.Gas != 9 {
+		t.Errorf("unexpected profile of f %+v", fn)
+	}
+	if fn := profile.Functions[1]; fn.Name != "(unattributed)" || fn.Count != 2 || fn.Gas != 2 {
+		t.Errorf("unexpected unattributed profile %+v", fn)
+	}
+}
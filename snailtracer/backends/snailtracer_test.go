@@ -0,0 +1,219 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+// testArtifacts are the artifacts built by the Makefile into the tracer's
+// testdata directory.
+var testArtifacts = func() Artifacts {
+	artifacts, err := LoadArtifacts("../testdata")
+	if err != nil {
+		panic(err)
+	}
+	return artifacts
+}()
+
+// newTestBackend creates, configures and sets up the named backend, closing it
+// when the test ends. Backends whose artifacts were not built are skipped.
+func newTestBackend(tb testing.TB, name string, configure ...func(Backend)) Backend {
+	backend, err := NewBackend(name, testArtifacts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, fn := range configure {
+		fn(backend)
+	}
+	if err := backend.Setup(); errors.Is(err, ErrMissingArtifact) {
+		tb.Skip(err)
+	} else if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := backend.Close(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return backend
+}
+
+func benchmarkBackend(b *testing.B, name string, configure ...func(Backend)) {
+	backend := newTestBackend(b, name, configure...)
+	gasReporter, meterGas := backend.(GasReporter)
+	instructionReporter, meterInstructions := backend.(InstructionReporter)
+	var gas, instructions uint64
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		cr, cg, cb, err := backend.Run(0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !snailtracer.ValidBenchmarkResult(cr, cg, cb) {
+			b.Fatal("invalid result:", cr, cg, cb)
+		}
+		if meterGas {
+			gas += gasReporter.GasUsed()
+		}
+		if meterInstructions {
+			instructions += instructionReporter.InstructionsUsed()
+		}
+	}
+	if meterGas && gas > 0 {
+		b.ReportMetric(float64(gas)/float64(b.N), "gas/op")
+		b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(gas), "ns/gas")
+	}
+	if meterInstructions && instructions > 0 {
+		b.ReportMetric(float64(instructions)/float64(b.N), "instructions/op")
+	}
+}
+
+// BenchmarkSnailtracer runs the benchmark on every registered backend.
+func BenchmarkSnailtracer(b *testing.B) {
+	for _, name := range BackendNames() {
+		b.Run(name, func(b *testing.B) {
+			benchmarkBackend(b, name)
+		})
+	}
+}
+
+// BenchmarkNativeSnailtracer, BenchmarkParallel4NativeSnailtracer,
+// BenchmarkEVMSnailtracer and BenchmarkTinygoSnailtracer are the original
+// benchmarks, kept under their names to compare with earlier results.
+func BenchmarkNativeSnailtracer(b *testing.B) {
+	benchmarkBackend(b, "native")
+}
+
+func BenchmarkParallel4NativeSnailtracer(b *testing.B) {
+	benchmarkBackend(b, "native/parallel4")
+}
+
+func BenchmarkEVMSnailtracer(b *testing.B) {
+	benchmarkBackend(b, "evm")
+}
+
+func BenchmarkTinygoSnailtracer(b *testing.B) {
+	for _, engine := range []string{"wasmer/singlepass", "wasmer/cranelift", "wazero/interpreter", "wazero/compiler"} {
+		for _, opt := range []string{"o2", "oz"} {
+			name := engine + "/" + opt
+			b.Run(name, func(b *testing.B) {
+				benchmarkBackend(b, name)
+			})
+		}
+	}
+}
+
+// BenchmarkSnailtracerMetered runs the benchmark on every Wasm backend with
+// instruction metering, to compare instructions/op with the EVM's gas/op.
+func BenchmarkSnailtracerMetered(b *testing.B) {
+	for _, name := range BackendNames() {
+		if !strings.HasPrefix(name, "wasmer/") && !strings.HasPrefix(name, "wazero/") {
+			continue
+		}
+		b.Run(name, func(b *testing.B) {
+			benchmarkBackend(b, name, func(backend Backend) {
+				backend.(InstructionMeter).SetMetering(0)
+			})
+		})
+	}
+}
+
+// BenchmarkParallel runs the benchmark on a growing number of instances of the
+// native and Wasm backends at once, one goroutine each, to compare how the
+// runtimes scale with the number of cores. Every instance is set up on its
+// own, e.g. with its own Wasm runtime. ns/op is the wall time per run over all
+// instances and runs/s the total throughput.
+func BenchmarkParallel(b *testing.B) {
+	for _, name := range BackendNames() {
+		if name != "native" && !strings.HasPrefix(name, "wasmer/") && !strings.HasPrefix(name, "wazero/") {
+			continue
+		}
+		for n := 1; n <= runtime.GOMAXPROCS(0); n *= 2 {
+			b.Run(fmt.Sprintf("%s/instances=%d", name, n), func(b *testing.B) {
+				benchmarkParallel(b, name, n)
+			})
+		}
+	}
+}
+
+func benchmarkParallel(b *testing.B, name string, instances int) {
+	backends := make([]Backend, instances)
+	for i := range backends {
+		backends[i] = newTestBackend(b, name)
+	}
+	var (
+		wg   sync.WaitGroup
+		runs int64
+		errs = make(chan error, instances)
+	)
+	b.ResetTimer()
+	start := time.Now()
+	for _, backend := range backends {
+		wg.Add(1)
+		go func(backend Backend) {
+			defer wg.Done()
+			for atomic.AddInt64(&runs, 1) <= int64(b.N) {
+				cr, cg, cb, err := backend.Run(0)
+				if err == nil && !snailtracer.ValidBenchmarkResult(cr, cg, cb) {
+					err = fmt.Errorf("invalid result: %d %d %d", cr, cg, cb)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(backend)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(errs)
+	for err := range errs {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "runs/s")
+}
+
+func TestBackends(t *testing.T) {
+	for _, name := range BackendNames() {
+		if strings.HasPrefix(name, "evm") || strings.HasPrefix(name, "wazero/interpreter/") {
+			continue // too slow for a unit test, covered by the benchmarks
+		}
+		t.Run(name, func(t *testing.T) {
+			backend := newTestBackend(t, name)
+			if backend.Name() != name {
+				t.Errorf("have name %q, want %q", backend.Name(), name)
+			}
+			r, g, b, err := backend.Run(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !snailtracer.ValidBenchmarkResult(r, g, b) {
+				t.Errorf("invalid result: %d %d %d", r, g, b)
+			}
+		})
+	}
+}
+
+func TestNewBackendUnknown(t *testing.T) {
+	if _, err := NewBackend("nope", testArtifacts); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+	backend, err := NewBackend("evm", Artifacts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Setup(); !errors.Is(err, ErrMissingArtifact) {
+		t.Fatalf("have error %v, want %v", err, ErrMissingArtifact)
+	}
+}
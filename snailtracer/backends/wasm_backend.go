@@ -0,0 +1,351 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	wz_api "github.com/tetratelabs/wazero/api"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// unpackRGB splits the color returned by the TinyGo module's exports into its
+// components.
+func unpackRGB(rgb int32) (r, g, b byte) {
+	return byte(rgb >> 16), byte(rgb >> 8), byte(rgb)
+}
+
+// wasmMeter is the metering configuration and state of a Wasm backend.
+type wasmMeter struct {
+	enabled      bool
+	fuel         uint64 // Zero for unlimited
+	instructions uint64 // Executed by the last run
+}
+
+// SetMetering instruments the module with MeterWasm to count the instructions
+// every run executes. A run executing more than fuel instructions is aborted
+// with ErrOutOfFuel.
+func (m *wasmMeter) SetMetering(fuel uint64) {
+	m.enabled, m.fuel = true, fuel
+}
+
+// InstructionsUsed returns the instructions executed by the last Run or call of
+// another export, or zero if metering is disabled.
+func (m *wasmMeter) InstructionsUsed() uint64 {
+	return m.instructions
+}
+
+// limit returns the value of the module's fuel global.
+func (m *wasmMeter) limit() uint64 {
+	if m.fuel == 0 {
+		return math.MaxUint64
+	}
+	return m.fuel
+}
+
+// instrument returns the module's code, metered if enabled.
+func (m *wasmMeter) instrument(code []byte) ([]byte, error) {
+	if !m.enabled {
+		return code, nil
+	}
+	return MeterWasm(code)
+}
+
+// done records the instructions counted by a run and turns a trap past the
+// fuel limit into ErrOutOfFuel.
+func (m *wasmMeter) done(instructions uint64, err error) error {
+	m.instructions = instructions
+	if err != nil && instructions > m.limit() {
+		return fmt.Errorf("%w after %d instructions: %v", ErrOutOfFuel, instructions, err)
+	}
+	return err
+}
+
+// meterGlobals accesses the meter globals of a metered instance.
+type meterGlobals interface {
+	// resetMeter zeroes the instruction counter and refuels the instance.
+	resetMeter(fuel uint64) error
+	// counted returns the instruction counter.
+	counted() (uint64, error)
+}
+
+// metered calls an export of the instance through call, with the instructions
+// counted and limited to the fuel if metering is enabled.
+func (m *wasmMeter) metered(g meterGlobals, call func() error) error {
+	if !m.enabled {
+		return call()
+	}
+	if err := g.resetMeter(m.limit()); err != nil {
+		return err
+	}
+	err := call()
+	instructions, countErr := g.counted()
+	if countErr != nil {
+		return countErr
+	}
+	return m.done(instructions, err)
+}
+
+// WasmerBackend runs the benchmark by calling the TinyGo module's run export
+// in Wasmer.
+type WasmerBackend struct {
+	wasmMeter
+	wasmHost
+	name     string
+	code     []byte
+	config   *wasmer.Config
+	instance *wasmer.Instance
+	run      wasmer.NativeFunction
+	counter  *wasmer.Global
+	fuel     *wasmer.Global
+}
+
+// NewWasmerBackend creates a Wasmer backend for the Wasm module, compiled with
+// the compiler selected in config.
+func NewWasmerBackend(name string, code []byte, config *wasmer.Config) *WasmerBackend {
+	return &WasmerBackend{name: name, code: code, config: config}
+}
+
+func (w *WasmerBackend) Name() string {
+	return w.name
+}
+
+func (w *WasmerBackend) Setup() error {
+	if len(w.code) == 0 {
+		return fmt.Errorf("%s: %w: Wasm module", w.name, ErrMissingArtifact)
+	}
+	code, err := w.instrument(w.code)
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.name, err)
+	}
+	instance, err := newWasmerInstance(code, w.config, &w.wasmHost)
+	if err != nil {
+		return err
+	}
+	run, err := instance.Exports.GetFunction("run")
+	if err != nil {
+		instance.Close()
+		return err
+	}
+	w.instance, w.run = instance, run
+	if w.enabled {
+		if w.counter, err = instance.Exports.GetGlobal(WasmInstructionsGlobal); err == nil {
+			w.fuel, err = instance.Exports.GetGlobal(WasmFuelGlobal)
+		}
+		if err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WasmerBackend) Run(seed int) (r, g, b byte, err error) {
+	var ret interface{}
+	err = w.metered(w, func() (err error) {
+		ret, err = w.run(int32(seed))
+		return err
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	r, g, b = unpackRGB(ret.(int32))
+	return r, g, b, nil
+}
+
+func (w *WasmerBackend) resetMeter(fuel uint64) error {
+	if err := w.counter.Set(int64(0), wasmer.I64); err != nil {
+		return err
+	}
+	return w.fuel.Set(int64(fuel), wasmer.I64)
+}
+
+func (w *WasmerBackend) counted() (uint64, error) {
+	counter, err := w.counter.Get()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(counter.(int64)), nil
+}
+
+func (w *WasmerBackend) Close() error {
+	if w.instance != nil {
+		w.instance.Close()
+		w.instance, w.run, w.counter, w.fuel = nil, nil, nil, nil
+	}
+	return nil
+}
+
+func newWasmerInstance(code []byte, config *wasmer.Config, host *wasmHost) (*wasmer.Instance, error) {
+	store, module, err := compileWasmer(code, config)
+	if err != nil {
+		return nil, err
+	}
+	return instantiateWasmer(store, module, host)
+}
+
+// compileWasmer compiles a module in a new store with the given compiler.
+func compileWasmer(code []byte, config *wasmer.Config) (*wasmer.Store, *wasmer.Module, error) {
+	store := wasmer.NewStore(wasmer.NewEngineWithConfig(config))
+	module, err := wasmer.NewModule(store, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, module, nil
+}
+
+// instantiateWasmer instantiates a compiled module with a fresh WASI
+// environment and the host functions.
+func instantiateWasmer(store *wasmer.Store, module *wasmer.Module, host *wasmHost) (*wasmer.Instance, error) {
+	wasiEnv, err := wasmer.NewWasiStateBuilder("wasi-program").Finalize()
+	if err != nil {
+		return nil, err
+	}
+	importObject, err := wasiEnv.GenerateImportObject(store, module)
+	if err != nil {
+		return nil, err
+	}
+	var memory *wasmer.Memory
+	importObject.Register("env", wasmerHostImports(store, host, func() *wasmer.Memory { return memory }))
+	instance, err := wasmer.NewInstance(module, importObject)
+	if err != nil {
+		return nil, err
+	}
+	memory, _ = instance.Exports.GetMemory("memory")
+	return instance, nil
+}
+
+// WazeroBackend runs the benchmark by calling the TinyGo module's run export
+// in wazero.
+type WazeroBackend struct {
+	wasmMeter
+	wasmHost
+	name    string
+	code    []byte
+	config  wazero.RuntimeConfig
+	runtime wazero.Runtime
+	module  wz_api.Module
+	run     wz_api.Function
+	counter wz_api.MutableGlobal
+	fuel    wz_api.MutableGlobal
+}
+
+// NewWazeroBackend creates a wazero backend for the Wasm module, executed by
+// the interpreter or compiler selected in config.
+func NewWazeroBackend(name string, code []byte, config wazero.RuntimeConfig) *WazeroBackend {
+	return &WazeroBackend{name: name, code: code, config: config}
+}
+
+func (w *WazeroBackend) Name() string {
+	return w.name
+}
+
+func (w *WazeroBackend) Setup() error {
+	if len(w.code) == 0 {
+		return fmt.Errorf("%s: %w: Wasm module", w.name, ErrMissingArtifact)
+	}
+	code, err := w.instrument(w.code)
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.name, err)
+	}
+	runtime, mod, err := newWazeroInstance(code, w.config, &w.wasmHost)
+	if err != nil {
+		return err
+	}
+	run := mod.ExportedFunction("run")
+	if run == nil {
+		runtime.Close(context.Background())
+		return fmt.Errorf("%s: module does not export run", w.name)
+	}
+	w.runtime, w.module, w.run = runtime, mod, run
+	if w.enabled {
+		counter, okCounter := mod.ExportedGlobal(WasmInstructionsGlobal).(wz_api.MutableGlobal)
+		fuel, okFuel := mod.ExportedGlobal(WasmFuelGlobal).(wz_api.MutableGlobal)
+		if !okCounter || !okFuel {
+			w.Close()
+			return fmt.Errorf("%s: module does not export the meter globals", w.name)
+		}
+		w.counter, w.fuel = counter, fuel
+	}
+	return nil
+}
+
+func (w *WazeroBackend) Run(seed int) (r, g, b byte, err error) {
+	var ret []uint64
+	err = w.metered(w, func() (err error) {
+		ret, err = w.run.Call(context.Background(), uint64(int32(seed)))
+		return err
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	r, g, b = unpackRGB(int32(ret[0]))
+	return r, g, b, nil
+}
+
+func (w *WazeroBackend) resetMeter(fuel uint64) error {
+	w.counter.Set(0)
+	w.fuel.Set(fuel)
+	return nil
+}
+
+func (w *WazeroBackend) counted() (uint64, error) {
+	return w.counter.Get(), nil
+}
+
+func (w *WazeroBackend) Close() error {
+	if w.runtime == nil {
+		return nil
+	}
+	err := w.runtime.Close(context.Background())
+	w.runtime, w.module, w.run, w.counter, w.fuel = nil, nil, nil, nil, nil
+	return err
+}
+
+func newWazeroInstance(code []byte, config wazero.RuntimeConfig, host *wasmHost) (wazero.Runtime, wz_api.Module, error) {
+	ctx := context.Background()
+	r, err := newWazeroRuntime(config, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	compiled, err := r.CompileModule(ctx, code)
+	if err != nil {
+		r.Close(ctx)
+		return nil, nil, err
+	}
+	mod, err := instantiateWazero(r, compiled)
+	if err != nil {
+		r.Close(ctx)
+		return nil, nil, err
+	}
+	return r, mod, nil
+}
+
+// newWazeroRuntime creates a runtime providing the host modules imported by
+// the TinyGo and Go modules.
+func newWazeroRuntime(config wazero.RuntimeConfig, host *wasmHost) (wazero.Runtime, error) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, config)
+
+	if err := instantiateWazeroHost(r, host); err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	return r, nil
+}
+
+// instantiateWazero instantiates a compiled module and runs its start
+// functions. Every instance is anonymous, so a module can be instantiated
+// more than once in the same runtime.
+func instantiateWazero(r wazero.Runtime, compiled wazero.CompiledModule) (wz_api.Module, error) {
+	// _initialize initializes modules built in reactor mode, like the standard
+	// Go build; TinyGo's _start returns from its empty main.
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_start", "_initialize")
+	return r.InstantiateModule(context.Background(), compiled, config)
+}
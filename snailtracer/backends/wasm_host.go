@@ -0,0 +1,89 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"context"
+
+	wz_api "github.com/tetratelabs/wazero/api"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// WasmHost receives the calls the Wasm module makes to the functions it
+// imports from the host's "env" module, see package wasmhost.
+type WasmHost interface {
+	// ReportProgress is called with the number of pixels traced so far by
+	// trace_scanline or trace_image, out of total.
+	ReportProgress(done, total int)
+	// Log is called with a message of the module.
+	Log(msg string)
+}
+
+// wasmHost forwards the module's host calls to the host set with SetHost, if
+// any.
+type wasmHost struct {
+	host WasmHost
+}
+
+// SetHost sets the host receiving the module's progress reports and log
+// messages. It has to be called before Setup.
+func (h *wasmHost) SetHost(host WasmHost) {
+	h.host = host
+}
+
+func (h *wasmHost) reportProgress(done, total int32) {
+	if h != nil && h.host != nil {
+		h.host.ReportProgress(int(done), int(total))
+	}
+}
+
+func (h *wasmHost) log(msg []byte) {
+	if h != nil && h.host != nil {
+		h.host.Log(string(msg))
+	}
+}
+
+// instantiateWazeroHost instantiates the "env" host module in r.
+func instantiateWazeroHost(r wazero.Runtime, h *wasmHost) error {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(done, total int32) {
+			h.reportProgress(done, total)
+		}).
+		Export("report_progress").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m wz_api.Module, ptr, size uint32) {
+			if msg, ok := m.Memory().Read(ptr, size); ok {
+				h.log(msg)
+			}
+		}).
+		Export("log").
+		Instantiate(context.Background())
+	return err
+}
+
+// wasmerHostImports returns the functions of the "env" host module. log reads
+// the message from the memory returned by memory, which is only known once
+// the module is instantiated.
+func wasmerHostImports(store *wasmer.Store, h *wasmHost, memory func() *wasmer.Memory) map[string]wasmer.IntoExtern {
+	params := wasmer.NewValueTypes(wasmer.I32, wasmer.I32)
+	results := wasmer.NewValueTypes()
+	return map[string]wasmer.IntoExtern{
+		"report_progress": wasmer.NewFunction(store, wasmer.NewFunctionType(params, results), func(args []wasmer.Value) ([]wasmer.Value, error) {
+			h.reportProgress(args[0].I32(), args[1].I32())
+			return nil, nil
+		}),
+		"log": wasmer.NewFunction(store, wasmer.NewFunctionType(params, results), func(args []wasmer.Value) ([]wasmer.Value, error) {
+			if mem := memory(); mem != nil {
+				data := mem.Data()
+				ptr, size := int(uint32(args[0].I32())), int(uint32(args[1].I32()))
+				if ptr+size <= len(data) {
+					h.log(data[ptr : ptr+size])
+				}
+			}
+			return nil, nil
+		}),
+	}
+}
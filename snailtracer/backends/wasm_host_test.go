@@ -0,0 +1,124 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// hostModule imports the host functions. Its run export reports progress 3 of
+// 4, logs "hello" and returns the benchmark color; host_calls(n) reports
+// progress n times.
+var hostModule = func() []byte {
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = append(module, encodeSection(1, // Type
+		[]byte{0x60, 0x01, 0x7f, 0x00},       // (i32) -> ()
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00}, // (i32, i32) -> ()
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f}, // (i32) -> i32
+	)...)
+	module = append(module, encodeSection(2, // Import
+		append(append(encodeName("wasi_snapshot_preview1"), encodeName("proc_exit")...), 0x00, 0x00),
+		append(append(encodeName("env"), encodeName("report_progress")...), 0x00, 0x01),
+		append(append(encodeName("env"), encodeName("log")...), 0x00, 0x01),
+	)...)
+	module = append(module, encodeSection(3, // Function
+		[]byte{2}, []byte{0},
+	)...)
+	module = append(module, encodeSection(5, // Memory
+		[]byte{0x00, 0x01},
+	)...)
+	module = append(module, encodeSection(7, // Export
+		encodeExport("memory", 0x02, 0),
+		encodeExport("run", 0x00, 3),
+		encodeExport("host_calls", 0x00, 4),
+	)...)
+	module = append(module, encodeSection(10, // Code
+		encodeBody([]byte{
+			0x41, 3, 0x41, 4, 0x10, 1, // report_progress(3, 4)
+			0x41, 0, 0x41, 5, 0x10, 2, // log(0, 5)
+			0x41, 0xb5, 0xa2, 0xc4, 0x00, // i32.const 0x111135
+		}),
+		encodeBody([]byte{
+			0x02, 0x40, 0x03, 0x40, // block, loop
+			0x20, 0x00, 0x45, 0x0d, 0x01, // br_if n == 0
+			0x20, 0x00, 0x20, 0x00, 0x10, 1, // report_progress(n, n)
+			0x20, 0x00, 0x41, 0x01, 0x6b, 0x21, 0x00, 0x0c, 0x00, // n--, br
+			0x0b, 0x0b,
+		}),
+	)...)
+	return append(module, encodeSection(11, // Data
+		append([]byte{0x00, 0x41, 0, 0x0b, 5}, "hello"...),
+	)...)
+}()
+
+type recordingHost struct {
+	progress [][2]int
+	logs     []string
+}
+
+func (h *recordingHost) ReportProgress(done, total int) {
+	h.progress = append(h.progress, [2]int{done, total})
+}
+
+func (h *recordingHost) Log(msg string) {
+	h.logs = append(h.logs, msg)
+}
+
+func TestWasmHost(t *testing.T) {
+	for _, backend := range []interface {
+		Backend
+		wasmModule
+		SetHost(WasmHost)
+	}{
+		NewWazeroBackend("wazero", hostModule, wazero.NewRuntimeConfigInterpreter()),
+		NewWasmerBackend("wasmer", hostModule, wasmer.NewConfig().UseCraneliftCompiler()),
+	} {
+		host := new(recordingHost)
+		backend.SetHost(host)
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", backend.Name(), err)
+		}
+		defer backend.Close()
+		r, g, b, err := backend.Run(0)
+		if err != nil {
+			t.Fatalf("%s: %v", backend.Name(), err)
+		}
+		if !snailtracer.ValidBenchmarkResult(r, g, b) {
+			t.Errorf("%s: invalid result: %d %d %d", backend.Name(), r, g, b)
+		}
+		if len(host.progress) != 1 || host.progress[0] != [2]int{3, 4} {
+			t.Errorf("%s: have progress %v, want [[3 4]]", backend.Name(), host.progress)
+		}
+		if strings.Join(host.logs, ",") != "hello" {
+			t.Errorf("%s: have logs %q, want [hello]", backend.Name(), host.logs)
+		}
+		if _, err := backend.call("host_calls", 10); err != nil {
+			t.Fatalf("%s: %v", backend.Name(), err)
+		}
+		if len(host.progress) != 11 {
+			t.Errorf("%s: have %d progress reports, want 11", backend.Name(), len(host.progress))
+		}
+	}
+}
+
+// BenchmarkWasmHostCalls measures the cost of a call from the Wasm module to
+// the host on every Wasm backend.
+func BenchmarkWasmHostCalls(b *testing.B) {
+	for _, name := range BackendNames() {
+		if !strings.HasPrefix(name, "wasmer/") && !strings.HasPrefix(name, "wazero/") {
+			continue
+		}
+		b.Run(name, func(b *testing.B) {
+			backend := newTestBackend(b, name).(wasmModule)
+			b.ResetTimer()
+			if _, err := backend.call("host_calls", int32(b.N)); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
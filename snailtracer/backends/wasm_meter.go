@@ -0,0 +1,388 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Names of the globals exported by a metered Wasm module.
+const (
+	// WasmInstructionsGlobal counts the instructions executed by the module.
+	WasmInstructionsGlobal = "meter_instructions"
+	// WasmFuelGlobal is the instruction count past which the module traps. It
+	// is initialized to the maximum, i.e. unlimited.
+	WasmFuelGlobal = "meter_fuel"
+)
+
+// ErrOutOfFuel is returned by metered Wasm backends when a run executes more
+// instructions than its fuel allows.
+var ErrOutOfFuel = errors.New("out of fuel")
+
+// InstructionReporter is implemented by backends that count executed
+// instructions.
+type InstructionReporter interface {
+	// InstructionsUsed returns the instructions executed by the last Run.
+	InstructionsUsed() uint64
+}
+
+// InstructionMeter is implemented by the Wasm backends, which can instrument
+// their module with MeterWasm.
+type InstructionMeter interface {
+	InstructionReporter
+	// SetMetering enables metering with the given fuel limit per run, zero
+	// for unlimited. It has to be called before Setup.
+	SetMetering(fuel uint64)
+}
+
+// MeterWasm instruments a Wasm module to count the instructions it executes.
+// Every function body is split into basic blocks at control instructions and
+// each block starts by adding its instruction count to the exported mutable
+// i64 global WasmInstructionsGlobal, then traps if the count exceeds the
+// exported WasmFuelGlobal. Instructions after a trap within the same block
+// are still counted. SIMD instructions are not supported.
+func MeterWasm(code []byte) ([]byte, error) {
+	if len(code) < 8 || !bytes.Equal(code[:4], []byte("\x00asm")) {
+		return nil, errors.New("not a Wasm module")
+	}
+	var sections []wasmSection
+	r := &wasmReader{buf: code, pos: 8}
+	for r.pos < len(r.buf) {
+		id := r.byte()
+		size := r.u32()
+		start := r.pos
+		r.skip(int(size))
+		if r.err != nil {
+			return nil, r.err
+		}
+		sections = append(sections, wasmSection{id, code[start:r.pos]})
+	}
+
+	for _, id := range []byte{wasmGlobalSection, wasmExportSection} {
+		if findSection(sections, id) == nil {
+			sections = insertSection(sections, wasmSection{id: id, data: []byte{0}})
+		}
+	}
+
+	// The counters are appended to the module's globals, after the imported
+	// and the defined ones.
+	var importedGlobals uint32
+	if imports := findSection(sections, wasmImportSection); imports != nil {
+		n, err := countGlobalImports(imports.data)
+		if err != nil {
+			return nil, err
+		}
+		importedGlobals = n
+	}
+	globals := findSection(sections, wasmGlobalSection)
+	counter := importedGlobals + (&wasmReader{buf: globals.data}).u32()
+	fuel := counter + 1
+	globals.data = appendVec(globals.data, 2, []byte{
+		0x7e, 0x01, 0x42, 0x00, 0x0b, // mut i64 = i64.const 0
+		0x7e, 0x01, 0x42, 0x7f, 0x0b, // mut i64 = i64.const -1
+	})
+
+	var newExports []byte
+	for _, export := range []struct {
+		name  string
+		index uint32
+	}{{WasmInstructionsGlobal, counter}, {WasmFuelGlobal, fuel}} {
+		newExports = appendU32(newExports, uint32(len(export.name)))
+		newExports = append(newExports, export.name...)
+		newExports = append(newExports, 0x03)
+		newExports = appendU32(newExports, export.index)
+	}
+	exports := findSection(sections, wasmExportSection)
+	exports.data = appendVec(exports.data, 2, newExports)
+
+	codeSection := findSection(sections, wasmCodeSection)
+	if codeSection != nil {
+		data, err := meterCode(codeSection.data, counter, fuel)
+		if err != nil {
+			return nil, err
+		}
+		codeSection.data = data
+	}
+
+	out := append([]byte{}, code[:8]...)
+	for _, s := range sections {
+		out = append(out, s.id)
+		out = appendU32(out, uint32(len(s.data)))
+		out = append(out, s.data...)
+	}
+	return out, nil
+}
+
+const (
+	wasmImportSection = 2
+	wasmGlobalSection = 6
+	wasmExportSection = 7
+	wasmCodeSection   = 10
+)
+
+type wasmSection struct {
+	id   byte
+	data []byte
+}
+
+// wasmSectionOrder is the position of every non-custom section in a module.
+var wasmSectionOrder = map[byte]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 13: 6, 6: 7, 7: 8, 8: 9, 9: 10, 12: 11, 10: 12, 11: 13}
+
+// insertSection inserts a section before the first non-custom section that
+// has to follow it.
+func insertSection(sections []wasmSection, s wasmSection) []wasmSection {
+	i := 0
+	for ; i < len(sections); i++ {
+		if order, ok := wasmSectionOrder[sections[i].id]; ok && order > wasmSectionOrder[s.id] {
+			break
+		}
+	}
+	sections = append(sections, wasmSection{})
+	copy(sections[i+1:], sections[i:])
+	sections[i] = s
+	return sections
+}
+
+func findSection(sections []wasmSection, id byte) *wasmSection {
+	for i := range sections {
+		if sections[i].id == id {
+			return &sections[i]
+		}
+	}
+	return nil
+}
+
+// appendVec appends n encoded items to the vector encoded in vec.
+func appendVec(vec []byte, n uint32, items []byte) []byte {
+	r := &wasmReader{buf: vec}
+	count := r.u32()
+	out := appendU32(nil, count+n)
+	out = append(out, vec[r.pos:]...)
+	return append(out, items...)
+}
+
+func countGlobalImports(data []byte) (uint32, error) {
+	r := &wasmReader{buf: data}
+	var globals uint32
+	for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+		r.skip(int(r.u32())) // Module
+		r.skip(int(r.u32())) // Name
+		switch kind := r.byte(); kind {
+		case 0x00: // Function
+			r.u32()
+		case 0x01: // Table
+			r.byte()
+			r.limits()
+		case 0x02: // Memory
+			r.limits()
+		case 0x03: // Global
+			r.skip(2)
+			globals++
+		default:
+			return 0, fmt.Errorf("unknown import kind %#x", kind)
+		}
+	}
+	return globals, r.err
+}
+
+// meterCode instruments every function body of the code section.
+func meterCode(data []byte, counter, fuel uint32) ([]byte, error) {
+	r := &wasmReader{buf: data}
+	n := r.u32()
+	out := appendU32(nil, n)
+	for i := uint32(0); i < n; i++ {
+		size := r.u32()
+		start := r.pos
+		r.skip(int(size))
+		if r.err != nil {
+			return nil, r.err
+		}
+		body, err := meterBody(data[start:r.pos], counter, fuel)
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %w", i, err)
+		}
+		out = appendU32(out, uint32(len(body)))
+		out = append(out, body...)
+	}
+	return out, nil
+}
+
+// meterBody instruments a function body, prefixing each basic block with the
+// instructions that count it and check the fuel.
+func meterBody(body []byte, counter, fuel uint32) ([]byte, error) {
+	r := &wasmReader{buf: body}
+	for i, n := uint32(0), r.u32(); i < n; i++ { // Locals
+		r.u32()
+		r.byte()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	out := append([]byte{}, body[:r.pos]...)
+
+	blockStart, instructions := r.pos, int64(0)
+	for depth := 1; depth > 0; {
+		if r.pos >= len(r.buf) {
+			return nil, errors.New("unterminated function body")
+		}
+		op := r.byte()
+		instructions++
+		if err := r.immediates(op); err != nil {
+			return nil, err
+		}
+		switch op {
+		case 0x02, 0x03, 0x04: // block, loop, if
+			depth++
+		case 0x0b: // end
+			depth--
+		case 0x05, 0x0c, 0x0d, 0x0e, 0x0f, 0x00: // else, br, br_if, br_table, return, unreachable
+		default:
+			continue
+		}
+		out = appendMeter(out, counter, fuel, instructions)
+		out = append(out, body[blockStart:r.pos]...)
+		blockStart, instructions = r.pos, 0
+	}
+	if r.pos != len(r.buf) {
+		return nil, errors.New("trailing bytes after function body")
+	}
+	return out, nil
+}
+
+// appendMeter appends the instructions adding n to the counter and trapping
+// when it exceeds the fuel.
+func appendMeter(out []byte, counter, fuel uint32, n int64) []byte {
+	out = append(out, 0x23) // global.get counter
+	out = appendU32(out, counter)
+	out = append(out, 0x42) // i64.const n
+	out = appendS64(out, n)
+	out = append(out, 0x7c, 0x24) // i64.add, global.set counter
+	out = appendU32(out, counter)
+
+	out = append(out, 0x23) // global.get counter
+	out = appendU32(out, counter)
+	out = append(out, 0x23) // global.get fuel
+	out = appendU32(out, fuel)
+	return append(out, 0x56, 0x04, 0x40, 0x00, 0x0b) // i64.gt_u, if, unreachable, end
+}
+
+type wasmReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.err = errors.New("unexpected end of module")
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *wasmReader) skip(n int) {
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = errors.New("unexpected end of module")
+		r.pos = len(r.buf)
+		return
+	}
+	r.pos += n
+}
+
+// u32 reads an unsigned LEB128 integer.
+func (r *wasmReader) u32() uint32 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = errors.New("invalid LEB128 integer")
+		r.pos = len(r.buf)
+		return 0
+	}
+	r.pos += n
+	return uint32(v)
+}
+
+// leb skips a signed or unsigned LEB128 integer.
+func (r *wasmReader) leb() {
+	for r.err == nil && r.byte()&0x80 != 0 {
+	}
+}
+
+func (r *wasmReader) limits() {
+	if flags := r.byte(); flags&1 != 0 {
+		r.u32()
+	}
+	r.u32()
+}
+
+// immediates skips the immediates of the instruction op.
+func (r *wasmReader) immediates(op byte) error {
+	switch {
+	case op == 0x02 || op == 0x03 || op == 0x04: // Block type
+		if r.pos < len(r.buf) && (r.buf[r.pos] == 0x40 || r.buf[r.pos] >= 0x6f && r.buf[r.pos] <= 0x7f) {
+			r.pos++
+		} else {
+			r.leb()
+		}
+	case op == 0x0c || op == 0x0d || op == 0x10 || op == 0xd2 || op >= 0x20 && op <= 0x26:
+		r.u32()
+	case op == 0x0e: // br_table
+		for i, n := uint32(0), r.u32(); i <= n && r.err == nil; i++ {
+			r.u32()
+		}
+	case op == 0x11: // call_indirect
+		r.u32()
+		r.u32()
+	case op == 0x1c: // select t*
+		r.skip(int(r.u32()))
+	case op >= 0x28 && op <= 0x3e: // Memory argument
+		r.u32()
+		r.u32()
+	case op == 0x3f || op == 0x40: // memory.size, memory.grow
+		r.u32()
+	case op == 0x41 || op == 0x42:
+		r.leb()
+	case op == 0x43:
+		r.skip(4)
+	case op == 0x44:
+		r.skip(8)
+	case op == 0xd0: // ref.null
+		r.byte()
+	case op == 0xfc:
+		switch sub := r.u32(); {
+		case sub <= 7: // Saturating truncation
+		case sub == 8 || sub == 10 || sub == 12 || sub == 14: // memory.init, memory.copy, table.init, table.copy
+			r.u32()
+			r.u32()
+		case sub <= 17:
+			r.u32()
+		default:
+			return fmt.Errorf("unsupported instruction 0xfc %d", sub)
+		}
+	case op == 0xfd:
+		return errors.New("SIMD instructions are not supported")
+	case op <= 0x01 || op == 0x05 || op == 0x0b || op == 0x0f || op == 0x1a || op == 0x1b || op >= 0x45 && op <= 0xc4 || op == 0xd1:
+	default:
+		return fmt.Errorf("unknown instruction %#x", op)
+	}
+	return r.err
+}
+
+func appendU32(out []byte, v uint32) []byte {
+	return binary.AppendUvarint(out, uint64(v))
+}
+
+func appendS64(out []byte, v int64) []byte {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && b&0x40 == 0 || v == -1 && b&0x40 != 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// loopModule exports run(n i32) i32, which counts n down to zero and returns
+// zero. It imports proc_exit and exports a memory for Wasmer's WASI environment.
+//
+//	block
+//	  loop
+//	    local.get 0
+//	    i32.eqz
+//	    br_if 1
+//	    local.get 0
+//	    i32.const 1
+//	    i32.sub
+//	    local.set 0
+//	    br 0
+//	  end
+//	end
+//	i32.const 0
+//	end
+var loopModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x0a, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x01, 0x7f, 0x00, // Type: (i32) -> i32, (i32) -> ()
+	0x02, 0x24, 0x01, 0x16, 'w', 'a', 's', 'i', '_', 's', 'n', 'a', 'p', 's', 'h', 'o', 't', '_', 'p', 'r', 'e', 'v', 'i', 'e', 'w', '1',
+	0x09, 'p', 'r', 'o', 'c', '_', 'e', 'x', 'i', 't', 0x00, 0x01, // Import
+	0x03, 0x02, 0x01, 0x00, // Function
+	0x05, 0x03, 0x01, 0x00, 0x01, // Memory
+	0x07, 0x10, 0x02, 0x03, 'r', 'u', 'n', 0x00, 0x01, 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00, // Export
+	0x0a, 0x1a, 0x01, 0x18, 0x00, // Code
+	0x02, 0x40, 0x03, 0x40,
+	0x20, 0x00, 0x45, 0x0d, 0x01,
+	0x20, 0x00, 0x41, 0x01, 0x6b, 0x21, 0x00, 0x0c, 0x00,
+	0x0b, 0x0b,
+	0x41, 0x00, 0x0b,
+}
+
+// loopInstructions is the number of instructions counted for run(n): the
+// block and loop headers, 8 per iteration, the final check and the return.
+// The skipped ends of the loop and the block are not counted.
+func loopInstructions(n int) uint64 {
+	return uint64(2 + 8*n + 3 + 2)
+}
+
+func TestMeterWasm(t *testing.T) {
+	for _, backend := range []interface {
+		Backend
+		InstructionMeter
+	}{
+		NewWazeroBackend("wazero/interpreter", loopModule, wazero.NewRuntimeConfigInterpreter()),
+		NewWazeroBackend("wazero/compiler", loopModule, wazero.NewRuntimeConfigCompiler()),
+		NewWasmerBackend("wasmer/cranelift", loopModule, wasmer.NewConfig().UseCraneliftCompiler()),
+	} {
+		backend.SetMetering(0)
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", backend.Name(), err)
+		}
+		defer backend.Close()
+		for _, n := range []int{0, 1, 10, 1000} {
+			if _, _, _, err := backend.Run(n); err != nil {
+				t.Fatalf("%s: run(%d): %v", backend.Name(), n, err)
+			}
+			if have, want := backend.InstructionsUsed(), loopInstructions(n); have != want {
+				t.Errorf("%s: run(%d): have %d instructions, want %d", backend.Name(), n, have, want)
+			}
+		}
+	}
+}
+
+func TestMeterWasmFuel(t *testing.T) {
+	for _, backend := range []interface {
+		Backend
+		InstructionMeter
+	}{
+		NewWazeroBackend("wazero", loopModule, wazero.NewRuntimeConfigCompiler()),
+		NewWasmerBackend("wasmer", loopModule, wasmer.NewConfig().UseCraneliftCompiler()),
+	} {
+		backend.SetMetering(loopInstructions(10))
+		if err := backend.Setup(); err != nil {
+			t.Fatal(err)
+		}
+		defer backend.Close()
+		if _, _, _, err := backend.Run(10); err != nil {
+			t.Errorf("%s: run(10): %v", backend.Name(), err)
+		}
+		if _, _, _, err := backend.Run(11); !errors.Is(err, ErrOutOfFuel) {
+			t.Errorf("%s: run(11): have error %v, want %v", backend.Name(), err, ErrOutOfFuel)
+		}
+		// The fuel is reset on every run.
+		if _, _, _, err := backend.Run(10); err != nil {
+			t.Errorf("%s: run(10) after running out of fuel: %v", backend.Name(), err)
+		}
+		// Calls to the other exports are metered the same way.
+		module := backend.(wasmModule)
+		if _, err := module.call("run", 11); !errors.Is(err, ErrOutOfFuel) {
+			t.Errorf("%s: call run(11): have error %v, want %v", backend.Name(), err, ErrOutOfFuel)
+		}
+		if _, err := module.call("run", 10); err != nil {
+			t.Errorf("%s: call run(10): %v", backend.Name(), err)
+		}
+		if have, want := backend.InstructionsUsed(), loopInstructions(10); have != want {
+			t.Errorf("%s: call run(10): have %d instructions, want %d", backend.Name(), have, want)
+		}
+	}
+}
+
+func TestMeterWasmInvalid(t *testing.T) {
+	if _, err := MeterWasm([]byte("not wasm")); err == nil {
+		t.Error("expected error for invalid module")
+	}
+	simd := append([]byte{}, loopModule...)
+	simd[len(simd)-3] = 0xfd // i32.const 0 -> SIMD prefix
+	if _, err := MeterWasm(simd); err == nil {
+		t.Error("expected error for SIMD instruction")
+	}
+}
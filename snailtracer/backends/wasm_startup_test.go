@@ -0,0 +1,248 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// wasmStartup are the steps of starting a module in one Wasm runtime. Every
+// step returns a function releasing what it created, which is called with the
+// timer stopped.
+type wasmStartup struct {
+	// compile compiles the module from scratch. It stops the timer around
+	// anything else it has to create.
+	compile func(b *testing.B) (release func())
+	// instantiate instantiates the module, compiled once beforehand, and
+	// returns its run export.
+	instantiate func(tb testing.TB) (run func(seed int32) int32, release func())
+	// startup creates a runtime, compiles and instantiates the module.
+	startup func(tb testing.TB) (release func())
+}
+
+// BenchmarkWasmStartup measures the cost of starting every Wasm backend, step
+// by step: compiling the module, instantiating the compiled module, the first
+// run on a fresh instance and a warm run, as well as the whole startup. For
+// wazero's compiler it also measures the startup with an on-disk compilation
+// cache, which the interpreter does not use.
+func BenchmarkWasmStartup(b *testing.B) {
+	for _, name := range BackendNames() {
+		backend, err := NewBackend(name, testArtifacts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var (
+			code    []byte
+			startup func(b *testing.B) wasmStartup
+		)
+		switch backend := backend.(type) {
+		case *WasmerBackend:
+			code = backend.code
+			startup = func(b *testing.B) wasmStartup { return wasmerStartup(b, backend.code, backend.config) }
+		case *WazeroBackend:
+			code = backend.code
+			startup = func(b *testing.B) wasmStartup { return wazeroStartup(b, backend.code, backend.config) }
+		default:
+			continue
+		}
+		b.Run(name, func(b *testing.B) {
+			if len(code) == 0 {
+				b.Skipf("%s: %v: Wasm module", name, ErrMissingArtifact)
+			}
+			benchmarkWasmStartup(b, startup(b))
+			if wazeroBackend, ok := backend.(*WazeroBackend); ok && strings.HasPrefix(name, "wazero/compiler/") {
+				b.Run("startup-cached", func(b *testing.B) {
+					benchmarkWazeroCachedStartup(b, code, wazeroBackend.config)
+				})
+			}
+		})
+	}
+}
+
+func benchmarkWasmStartup(b *testing.B, steps wasmStartup) {
+	b.Run("compile", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			release := steps.compile(b)
+			b.StopTimer()
+			release()
+			b.StartTimer()
+		}
+	})
+	b.Run("instantiate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, release := steps.instantiate(b)
+			b.StopTimer()
+			release()
+			b.StartTimer()
+		}
+	})
+	b.Run("first-call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			run, release := steps.instantiate(b)
+			b.StartTimer()
+			checkWasmRun(b, run(0))
+			b.StopTimer()
+			release()
+			b.StartTimer()
+		}
+	})
+	b.Run("warm-call", func(b *testing.B) {
+		run, release := steps.instantiate(b)
+		defer release()
+		checkWasmRun(b, run(0))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			checkWasmRun(b, run(0))
+		}
+	})
+	b.Run("startup", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			release := steps.startup(b)
+			b.StopTimer()
+			release()
+			b.StartTimer()
+		}
+	})
+}
+
+// benchmarkWazeroCachedStartup measures the startup of a runtime whose
+// compilation cache directory was filled by an earlier runtime. Every
+// iteration opens the cache anew, so the compiled code is read from disk.
+func benchmarkWazeroCachedStartup(b *testing.B, code []byte, config wazero.RuntimeConfig) {
+	dir := b.TempDir()
+	startup := func() (release func()) {
+		cache, err := wazero.NewCompilationCacheWithDir(dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		r, _, err := newWazeroInstance(code, config.WithCompilationCache(cache), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return func() {
+			r.Close(context.Background())
+			cache.Close(context.Background())
+		}
+	}
+	startup()()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		release := startup()
+		b.StopTimer()
+		release()
+		b.StartTimer()
+	}
+}
+
+func checkWasmRun(tb testing.TB, rgb int32) {
+	if r, g, b := unpackRGB(rgb); !snailtracer.ValidBenchmarkResult(r, g, b) {
+		tb.Fatal("invalid result:", r, g, b)
+	}
+}
+
+func wazeroStartup(tb testing.TB, code []byte, config wazero.RuntimeConfig) wasmStartup {
+	ctx := context.Background()
+	runtime, err := newWazeroRuntime(config, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { runtime.Close(ctx) })
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return wasmStartup{
+		compile: func(b *testing.B) func() {
+			// A runtime caches the modules it compiled, so every compilation
+			// needs a new one.
+			b.StopTimer()
+			r, err := newWazeroRuntime(config, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			if _, err := r.CompileModule(ctx, code); err != nil {
+				b.Fatal(err)
+			}
+			return func() { r.Close(ctx) }
+		},
+		instantiate: func(tb testing.TB) (func(int32) int32, func()) {
+			mod, err := instantiateWazero(runtime, compiled)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			run := mod.ExportedFunction("run")
+			return func(seed int32) int32 {
+				ret, err := run.Call(ctx, uint64(uint32(seed)))
+				if err != nil {
+					tb.Fatal(err)
+				}
+				return int32(ret[0])
+			}, func() { mod.Close(ctx) }
+		},
+		startup: func(tb testing.TB) func() {
+			r, _, err := newWazeroInstance(code, config, nil)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return func() { r.Close(ctx) }
+		},
+	}
+}
+
+func wasmerStartup(tb testing.TB, code []byte, config *wasmer.Config) wasmStartup {
+	store, module, err := compileWasmer(code, config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		module.Close()
+		store.Close()
+	})
+	return wasmStartup{
+		compile: func(b *testing.B) func() {
+			b.StopTimer()
+			store := wasmer.NewStore(wasmer.NewEngineWithConfig(config))
+			b.StartTimer()
+			module, err := wasmer.NewModule(store, code)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return func() {
+				module.Close()
+				store.Close()
+			}
+		},
+		instantiate: func(tb testing.TB) (func(int32) int32, func()) {
+			instance, err := instantiateWasmer(store, module, nil)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			run, err := instance.Exports.GetFunction("run")
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return func(seed int32) int32 {
+				ret, err := run(seed)
+				if err != nil {
+					tb.Fatal(err)
+				}
+				return ret.(int32)
+			}, instance.Close
+		},
+		startup: func(tb testing.TB) func() {
+			instance, err := newWasmerInstance(code, config, nil)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return instance.Close
+		},
+	}
+}
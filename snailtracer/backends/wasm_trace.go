@@ -0,0 +1,211 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	wz_api "github.com/tetratelabs/wazero/api"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+// wasmModule calls the tracing exports of the TinyGo module instantiated by a
+// Wasm backend. trace_scanline and trace_image return the address of the
+// traced RGB bytes in linear memory, which stay valid until the next call.
+type wasmModule interface {
+	call(name string, args ...int32) (int32, error)
+	read(ptr int32, size int) ([]byte, error)
+}
+
+var errOutOfBounds = errors.New("out of bounds memory read")
+
+// wasmTracePixel calls the module's trace_pixel export.
+func wasmTracePixel(m wasmModule, x, y, spp int) (r, g, b byte, err error) {
+	rgb, err := m.call("trace_pixel", int32(x), int32(y), int32(spp))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	r, g, b = unpackRGB(rgb)
+	return r, g, b, nil
+}
+
+// wasmSetScale calls the module's set_scale export.
+func wasmSetScale(m wasmModule, digits int) error {
+	ok, err := m.call("set_scale", int32(digits))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return fmt.Errorf("scale 1e%d out of range [1e%d, 1e%d]", digits, snailtracer.MinScaleDigits, snailtracer.MaxScaleDigits)
+	}
+	return nil
+}
+
+// wasmSetMath calls the module's set_math export.
+func wasmSetMath(m wasmModule, mode snailtracer.MathMode) error {
+	_, err := m.call("set_math", int32(mode))
+	return err
+}
+
+// wasmTraceScanline calls the module's trace_scanline export and copies the
+// scanline out of linear memory.
+func wasmTraceScanline(m wasmModule, y, spp int) ([]byte, error) {
+	width, err := m.call("image_width")
+	if err != nil {
+		return nil, err
+	}
+	ptr, err := m.call("trace_scanline", int32(y), int32(spp))
+	if err != nil {
+		return nil, err
+	}
+	return m.read(ptr, 3*int(width))
+}
+
+// wasmTraceImage calls the module's trace_image export and copies the image
+// out of linear memory.
+func wasmTraceImage(m wasmModule, spp int) ([]byte, error) {
+	width, err := m.call("image_width")
+	if err != nil {
+		return nil, err
+	}
+	height, err := m.call("image_height")
+	if err != nil {
+		return nil, err
+	}
+	ptr, err := m.call("trace_image", int32(spp))
+	if err != nil {
+		return nil, err
+	}
+	return m.read(ptr, 3*int(width)*int(height))
+}
+
+// SetScale calls the module's set_scale export, tracing at the fixed-point
+// scale 1e<digits> from then on.
+func (w *WasmerBackend) SetScale(digits int) error {
+	return wasmSetScale(w, digits)
+}
+
+// SetMath calls the module's set_math export, tracing with the math mode from
+// then on.
+func (w *WasmerBackend) SetMath(mode snailtracer.MathMode) error {
+	return wasmSetMath(w, mode)
+}
+
+// TracePixel calls the module's trace_pixel export.
+func (w *WasmerBackend) TracePixel(x, y, spp int) (r, g, b byte, err error) {
+	return wasmTracePixel(w, x, y, spp)
+}
+
+// TraceScanline calls the module's trace_scanline export and returns the RGB
+// bytes of the scanline, left-to-right, like Scene.TraceScanline.
+func (w *WasmerBackend) TraceScanline(y, spp int) ([]byte, error) {
+	return wasmTraceScanline(w, y, spp)
+}
+
+// TraceImage calls the module's trace_image export and returns the RGB bytes
+// of the image, top-down, left-to-right, like Scene.TraceImage.
+func (w *WasmerBackend) TraceImage(spp int) ([]byte, error) {
+	return wasmTraceImage(w, spp)
+}
+
+func (w *WasmerBackend) call(name string, args ...int32) (int32, error) {
+	fn, err := w.instance.Exports.GetFunction(name)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", w.name, err)
+	}
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = arg
+	}
+	var ret interface{}
+	err = w.metered(w, func() (err error) {
+		ret, err = fn(params...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s: %w", w.name, name, err)
+	}
+	if ret == nil {
+		return 0, nil // No result
+	}
+	return ret.(int32), nil
+}
+
+func (w *WasmerBackend) read(ptr int32, size int) ([]byte, error) {
+	memory, err := w.instance.Exports.GetMemory("memory")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", w.name, err)
+	}
+	data := memory.Data()
+	if start := int(uint32(ptr)); start+size <= len(data) {
+		return append([]byte{}, data[start:start+size]...), nil
+	}
+	return nil, fmt.Errorf("%s: %w: %d bytes at %#x", w.name, errOutOfBounds, size, uint32(ptr))
+}
+
+// SetScale calls the module's set_scale export, tracing at the fixed-point
+// scale 1e<digits> from then on.
+func (w *WazeroBackend) SetScale(digits int) error {
+	return wasmSetScale(w, digits)
+}
+
+// SetMath calls the module's set_math export, tracing with the math mode from
+// then on.
+func (w *WazeroBackend) SetMath(mode snailtracer.MathMode) error {
+	return wasmSetMath(w, mode)
+}
+
+// TracePixel calls the module's trace_pixel export.
+func (w *WazeroBackend) TracePixel(x, y, spp int) (r, g, b byte, err error) {
+	return wasmTracePixel(w, x, y, spp)
+}
+
+// TraceScanline calls the module's trace_scanline export and returns the RGB
+// bytes of the scanline, left-to-right, like Scene.TraceScanline.
+func (w *WazeroBackend) TraceScanline(y, spp int) ([]byte, error) {
+	return wasmTraceScanline(w, y, spp)
+}
+
+// TraceImage calls the module's trace_image export and returns the RGB bytes
+// of the image, top-down, left-to-right, like Scene.TraceImage.
+func (w *WazeroBackend) TraceImage(spp int) ([]byte, error) {
+	return wasmTraceImage(w, spp)
+}
+
+func (w *WazeroBackend) call(name string, args ...int32) (int32, error) {
+	fn := w.module.ExportedFunction(name)
+	if fn == nil {
+		return 0, fmt.Errorf("%s: module does not export %s", w.name, name)
+	}
+	params := make([]uint64, len(args))
+	for i, arg := range args {
+		params[i] = wz_api.EncodeI32(arg)
+	}
+	var ret []uint64
+	err := w.metered(w, func() (err error) {
+		ret, err = fn.Call(context.Background(), params...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s: %w", w.name, name, err)
+	}
+	if len(ret) == 0 {
+		return 0, nil // No result
+	}
+	return wz_api.DecodeI32(ret[0]), nil
+}
+
+func (w *WazeroBackend) read(ptr int32, size int) ([]byte, error) {
+	memory := w.module.Memory()
+	if memory == nil {
+		return nil, fmt.Errorf("%s: module does not export its memory", w.name)
+	}
+	data, ok := memory.Read(uint32(ptr), uint32(size))
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: %d bytes at %#x", w.name, errOutOfBounds, size, uint32(ptr))
+	}
+	return append([]byte{}, data...), nil
+}
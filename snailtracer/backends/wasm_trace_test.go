@@ -0,0 +1,180 @@
+//go:build !tinygo && !wasip1
+
+package backends
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/wasmerio/wasmer-go/wasmer"
+
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+)
+
+// traceModule mimics the tracing exports of the TinyGo module for a 2x1 image
+// whose pixels are stored at address 16: trace_pixel returns the color
+// 0x010203 and trace_scanline and trace_image the address of the pixels. It
+// exports trace_image as run for Setup, and imports proc_exit for Wasmer's WASI
+// environment.
+func traceModule(imageHeight int32) []byte {
+	constBody := func(v int32) []byte {
+		return encodeBody(append([]byte{0x41}, appendS64(nil, int64(v))...))
+	}
+
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = append(module, encodeSection(1, // Type
+		[]byte{0x60, 0x01, 0x7f, 0x00},                   // (i32) -> ()
+		[]byte{0x60, 0x00, 0x01, 0x7f},                   // () -> i32
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f},       // (i32, i32) -> i32
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},             // (i32) -> i32
+		[]byte{0x60, 0x03, 0x7f, 0x7f, 0x7f, 0x01, 0x7f}, // (i32, i32, i32) -> i32
+	)...)
+	module = append(module, encodeSection(2, // Import
+		append(append(encodeName("wasi_snapshot_preview1"), encodeName("proc_exit")...), 0x00, 0x00),
+	)...)
+	module = append(module, encodeSection(3, // Function
+		[]byte{1}, []byte{1}, []byte{2}, []byte{3}, []byte{4},
+	)...)
+	module = append(module, encodeSection(5, // Memory
+		[]byte{0x00, 0x01},
+	)...)
+	module = append(module, encodeSection(7, // Export
+		encodeExport("memory", 0x02, 0),
+		encodeExport("run", 0x00, 4),
+		encodeExport("image_width", 0x00, 1),
+		encodeExport("image_height", 0x00, 2),
+		encodeExport("trace_scanline", 0x00, 3),
+		encodeExport("trace_image", 0x00, 4),
+		encodeExport("trace_pixel", 0x00, 5),
+	)...)
+	module = append(module, encodeSection(10, // Code
+		constBody(2),
+		constBody(imageHeight),
+		constBody(16),
+		constBody(16),
+		constBody(0x010203),
+	)...)
+	return append(module, encodeSection(11, // Data
+		[]byte{0x00, 0x41, 16, 0x0b, 6, 1, 2, 3, 4, 5, 6},
+	)...)
+}
+
+// encodeSection encodes a section of a test module holding a vector of items.
+func encodeSection(id byte, items ...[]byte) []byte {
+	vec := appendU32(nil, uint32(len(items)))
+	for _, item := range items {
+		vec = append(vec, item...)
+	}
+	return append(appendU32([]byte{id}, uint32(len(vec))), vec...)
+}
+
+func encodeName(s string) []byte {
+	return append(appendU32(nil, uint32(len(s))), s...)
+}
+
+func encodeExport(s string, kind, index byte) []byte {
+	return append(encodeName(s), kind, index)
+}
+
+// encodeBody encodes a function body without locals.
+func encodeBody(instructions []byte) []byte {
+	body := append([]byte{0x00}, instructions...)
+	body = append(body, 0x0b)
+	return append(appendU32(nil, uint32(len(body))), body...)
+}
+
+func TestWasmTrace(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		backend func(code []byte) Backend
+	}{
+		{"wazero", func(code []byte) Backend {
+			return NewWazeroBackend("wazero", code, wazero.NewRuntimeConfigInterpreter())
+		}},
+		{"wasmer", func(code []byte) Backend {
+			return NewWasmerBackend("wasmer", code, wasmer.NewConfig().UseCraneliftCompiler())
+		}},
+	} {
+		backend := tt.backend(traceModule(1))
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		defer backend.Close()
+		tracer := backend.(ImageTracer)
+		if r, g, b, err := tracer.TracePixel(0, 0, 1); err != nil || r != 1 || g != 2 || b != 3 {
+			t.Errorf("%s: TracePixel: have %d %d %d, %v, want 1 2 3", tt.name, r, g, b, err)
+		}
+		want := []byte{1, 2, 3, 4, 5, 6}
+		if have, err := tracer.TraceScanline(0, 1); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("%s: TraceScanline: have %v, %v, want %v", tt.name, have, err, want)
+		}
+		if have, err := tracer.TraceImage(1); err != nil || !bytes.Equal(have, want) {
+			t.Errorf("%s: TraceImage: have %v, %v, want %v", tt.name, have, err, want)
+		}
+
+		// An image taller than the memory must not be read past its end.
+		backend = tt.backend(traceModule(1 << 20))
+		if err := backend.Setup(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		defer backend.Close()
+		tracer = backend.(ImageTracer)
+		if _, err := tracer.TraceImage(1); !errors.Is(err, errOutOfBounds) {
+			t.Errorf("%s: TraceImage: have error %v, want %v", tt.name, err, errOutOfBounds)
+		}
+	}
+}
+
+// wasmTraceBackends are the Wasm backends benchmarked at other scales and in
+// other math modes.
+var wasmTraceBackends = []string{"wazero/compiler/o2", "wazero/compiler/go", "wasmer/cranelift/o2"}
+
+// benchmarkTracePixels traces the benchmark pixels on the backend once per
+// iteration.
+func benchmarkTracePixels(b *testing.B, backend Backend) {
+	tracer := backend.(ImageTracer)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range snailtracer.BenchmarkPixels {
+			if _, _, _, err := tracer.TracePixel(p.X, p.Y, p.SPP); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkScale traces the benchmark pixels at the 1e4, 1e6 and 1e9 scales on
+// the Wasm backends, like the tracer's BenchmarkScale does natively. The EVM
+// backends are left out as the contract only traces at 1e6.
+func BenchmarkScale(b *testing.B) {
+	for _, digits := range []int{4, 6, 9} {
+		for _, name := range wasmTraceBackends {
+			b.Run(fmt.Sprintf("%s/1e%d", name, digits), func(b *testing.B) {
+				backend := newTestBackend(b, name)
+				if err := backend.(Scaler).SetScale(digits); err != nil {
+					b.Fatal(err)
+				}
+				benchmarkTracePixels(b, backend)
+			})
+		}
+	}
+}
+
+// BenchmarkMathModes traces the benchmark pixels in every math mode on the Wasm
+// backends, like the tracer's BenchmarkMathModes does natively.
+func BenchmarkMathModes(b *testing.B) {
+	for _, mode := range []snailtracer.MathMode{snailtracer.ContractMath, snailtracer.FastSqrt, snailtracer.FastTrig, snailtracer.FastMath} {
+		for _, name := range wasmTraceBackends {
+			b.Run(name+"/"+mode.String(), func(b *testing.B) {
+				backend := newTestBackend(b, name)
+				if err := backend.(MathSetter).SetMath(mode); err != nil {
+					b.Fatal(err)
+				}
+				benchmarkTracePixels(b, backend)
+			})
+		}
+	}
+}
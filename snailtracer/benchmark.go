@@ -6,14 +6,10 @@ func NewBenchmarkScene(id int, seed int) *Scene {
 	s := newScene(1024, 768, seed)
 
 	s.id = id
-	s.camera = &Ray{
-		origin:    NewVector(50000000, 50000000, 295600000),
-		direction: (NewVector(0, -42612, -1000000)).Norm(),
-	}
-	s.deltaX = NewVector(int64(s.width*513500/s.height), 0, 0)
-	s.deltaY = s.deltaX.Cross(s.camera.direction).Norm().
-		ScaleMul(uint256.NewInt(513500)).
-		ScaleDiv(uint256.NewInt(1000000))
+	s.setCamera(
+		NewVector(50000000, 50000000, 295600000),
+		(NewVector(0, -42612, -1000000)).Norm(),
+	)
 
 	s.spheres = []*Sphere{
 		{uint256.NewInt(100000000000), NewVector(100001000000, 40800000, 81600000), NewVector(0, 0, 0), NewVector(750000, 250000, 250000), DiffuseMaterial},
@@ -41,11 +37,26 @@ func NewBenchmarkScene(id int, seed int) *Scene {
 		{NewVector(56500000, 23760000, 78000000), NewVector(73000000, 23760000, 94500000), NewVector(89500000, 23760000, 78000000), NewVector(0, 0, 0), NewVector(0, 0, 0), NewVector(999000, 999000, 999000), SpecularMaterial},
 	}
 
-	// Calculate all the triangle surface normals
-	for i := range s.triangles {
-		tri := s.triangles[i]
-		tri.normal = tri.b.Sub(tri.a).Cross(tri.c.Sub(tri.a)).Norm()
-	}
+	s.prepare()
 
 	return s
 }
+
+// BenchmarkPixel is a pixel of the benchmark scene traced at SPP samples per
+// pixel.
+type BenchmarkPixel struct{ X, Y, SPP int }
+
+// BenchmarkPixels are the pixels traced by the contract's Benchmark method,
+// whose colors it averages.
+var BenchmarkPixels = []BenchmarkPixel{
+	{512, 384, 8}, // Flat diffuse surface, opposite wall
+	{325, 540, 8}, // Reflective surface mirroring left wall
+	{600, 600, 8}, // Refractive surface reflecting right wall
+	{522, 524, 8}, // Reflective surface mirroring the refractive surface reflecting the light
+}
+
+// ValidBenchmarkResult reports whether r, g and b are the color the benchmark
+// is expected to produce.
+func ValidBenchmarkResult(r, g, b byte) bool {
+	return r == 17 && g == 17 && b == 53
+}
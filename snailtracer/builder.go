@@ -0,0 +1,181 @@
+package snailtracer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+)
+
+// maxSceneValue bounds the magnitude of every scene coordinate, color and
+// radius. Triangle intersection multiplies three coordinate differences
+// together (the edge, the cross product of two more edges), so values have to
+// stay well below the cube root of the int256 range.
+var maxSceneValue = new(uint256.Int).Lsh(Big1, 80)
+
+// SceneBuilder assembles a custom scene, deriving the camera field of view and
+// the triangle normals the same way NewBenchmarkScene does.
+type SceneBuilder struct {
+	width, height     int
+	scale             *Scale
+	origin, direction Vector
+	hasCamera         bool
+	spheres           []*Sphere
+	triangles         []*Triangle
+}
+
+func NewSceneBuilder() *SceneBuilder {
+	return &SceneBuilder{width: 1024, height: 768, scale: DefaultScale}
+}
+
+// Scale sets the fixed-point scale of the vectors and radii passed to the
+// builder and of the scene it builds, DefaultScale by default. Meshes are
+// loaded at the same scale with OBJOptions.SceneScale.
+func (sb *SceneBuilder) Scale(sc *Scale) *SceneBuilder {
+	sb.scale = sc
+	return sb
+}
+
+// Resolution sets the size of the rendered image, 1024x768 by default.
+func (sb *SceneBuilder) Resolution(width, height int) *SceneBuilder {
+	sb.width = width
+	sb.height = height
+	return sb
+}
+
+// Camera places the camera. The direction is normalized by the builder.
+func (sb *SceneBuilder) Camera(origin, direction Vector) *SceneBuilder {
+	sb.origin = copyVector(origin)
+	sb.direction = copyVector(direction)
+	sb.hasCamera = true
+	return sb
+}
+
+// AddSphere appends a copy of a sphere to the scene.
+func (sb *SceneBuilder) AddSphere(radius *uint256.Int, position, emission, color Vector, reflection Material) *SceneBuilder {
+	sb.spheres = append(sb.spheres, &Sphere{copyInt(radius), copyVector(position), copyVector(emission), copyVector(color), reflection})
+	return sb
+}
+
+// AddTriangle appends a copy of a triangle to the scene, its normal is computed
+// on Build.
+func (sb *SceneBuilder) AddTriangle(a, b, c, emission, color Vector, reflection Material) *SceneBuilder {
+	sb.triangles = append(sb.triangles, &Triangle{
+		a:          copyVector(a),
+		b:          copyVector(b),
+		c:          copyVector(c),
+		emission:   copyVector(emission),
+		color:      copyVector(color),
+		reflection: reflection,
+	})
+	return sb
+}
+
+// Build validates the scene and prepares it for rendering.
+func (sb *SceneBuilder) Build(id, seed int) (*Scene, error) {
+	if sb.width <= 0 || sb.height <= 0 {
+		return nil, fmt.Errorf("invalid scene resolution %dx%d", sb.width, sb.height)
+	}
+	if !sb.hasCamera {
+		return nil, errors.New("scene has no camera")
+	}
+	if err := checkVector("camera direction", sb.direction); err != nil {
+		return nil, err
+	}
+	if Cmp(sb.direction.Length(), Big0) == 0 {
+		return nil, errors.New("camera direction is zero")
+	}
+
+	s := newScene(sb.width, sb.height, seed)
+	s.id = id
+	s.scale = sb.scale
+	s.setCamera(sb.origin, sb.direction.norm(sb.scale.one))
+	s.spheres = make([]*Sphere, len(sb.spheres))
+	for i, sphere := range sb.spheres {
+		copied := *sphere
+		s.spheres[i] = &copied
+	}
+	s.triangles = make([]*Triangle, len(sb.triangles))
+	for i, tri := range sb.triangles {
+		copied := *tri
+		s.triangles[i] = &copied
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	s.prepare()
+	return s, nil
+}
+
+// validate checks that the scene can be rendered without degenerate primitives
+// or fixed-point values that overflow during intersection.
+func (s *SceneOf[T]) validate() error {
+	if s.width <= 0 || s.height <= 0 {
+		return fmt.Errorf("invalid scene resolution %dx%d", s.width, s.height)
+	}
+	if err := checkVector("camera origin", s.camera.origin.Vector()); err != nil {
+		return err
+	}
+	for i, sphere := range s.spheres {
+		if sphere.radius == nil {
+			return fmt.Errorf("sphere %d: nil radius", i)
+		}
+		if sphere.radius.Sign() <= 0 {
+			return fmt.Errorf("sphere %d: radius must be positive", i)
+		}
+		if !inSceneRange(sphere.radius) {
+			return fmt.Errorf("sphere %d: radius exceeds 2^80", i)
+		}
+		for _, v := range []struct {
+			name string
+			v    Vector
+		}{{"position", sphere.position}, {"emission", sphere.emission}, {"color", sphere.color}} {
+			if err := checkVector(fmt.Sprintf("sphere %d: %s", i, v.name), v.v); err != nil {
+				return err
+			}
+		}
+	}
+	for i, tri := range s.triangles {
+		for _, v := range []struct {
+			name string
+			v    Vector
+		}{{"a", tri.a}, {"b", tri.b}, {"c", tri.c}, {"emission", tri.emission}, {"color", tri.color}} {
+			if err := checkVector(fmt.Sprintf("triangle %d: %s", i, v.name), v.v); err != nil {
+				return err
+			}
+		}
+		if Cmp(tri.b.Sub(tri.a).Cross(tri.c.Sub(tri.a)).Length(), Big0) == 0 {
+			return fmt.Errorf("triangle %d: degenerate, vertices are collinear", i)
+		}
+	}
+	return nil
+}
+
+// copyVector returns a copy of v that shares no component with it, leaving
+// unset components unset for validate to report.
+func copyVector(v Vector) Vector {
+	return Vector{copyInt(v.X), copyInt(v.Y), copyInt(v.Z)}
+}
+
+// copyInt returns a copy of x, or nil for validate to report if x is unset.
+func copyInt(x *uint256.Int) *uint256.Int {
+	if x == nil {
+		return nil
+	}
+	return new(uint256.Int).Set(x)
+}
+
+func inSceneRange(x *uint256.Int) bool {
+	a := Abs(x)
+	return a.Sign() >= 0 && Cmp(a, maxSceneValue) < 0
+}
+
+func checkVector(name string, v Vector) error {
+	if v.X == nil || v.Y == nil || v.Z == nil {
+		return fmt.Errorf("%s: vector is unset", name)
+	}
+	if !inSceneRange(v.X) || !inSceneRange(v.Y) || !inSceneRange(v.Z) {
+		return fmt.Errorf("%s: component exceeds 2^80", name)
+	}
+	return nil
+}
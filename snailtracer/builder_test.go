@@ -0,0 +1,98 @@
+package snailtracer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// newBenchmarkSceneBuilder returns a builder holding the benchmark scene's
+// camera and spheres, and optionally its prism of triangles.
+func newBenchmarkSceneBuilder(triangles bool) *SceneBuilder {
+	room := NewBenchmarkScene(0, 0)
+	b := NewSceneBuilder().
+		Resolution(1024, 768).
+		Camera(NewVector(50000000, 50000000, 295600000), NewVector(0, -42612, -1000000))
+	for _, sphere := range room.spheres {
+		b.AddSphere(sphere.radius, sphere.position, sphere.emission, sphere.color, sphere.reflection)
+	}
+	if triangles {
+		for _, tri := range room.triangles {
+			b.AddTriangle(tri.a, tri.b, tri.c, tri.emission, tri.color, tri.reflection)
+		}
+	}
+	return b
+}
+
+func TestSceneBuilderBenchmarkScene(t *testing.T) {
+	s, err := newBenchmarkSceneBuilder(true).Build(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, NewBenchmarkScene(1, 2)) {
+		t.Fatal("built scene differs from the benchmark scene")
+	}
+}
+
+func TestSceneBuilderErrors(t *testing.T) {
+	var (
+		zero   = NewVector(0, 0, 0)
+		white  = NewVector(750000, 750000, 750000)
+		huge   = new(uint256.Int).Lsh(Big1, 80)
+		camera = func() *SceneBuilder {
+			return NewSceneBuilder().Camera(NewVector(0, 0, 0), NewVector(0, 0, -1000000))
+		}
+	)
+	tests := []struct {
+		name    string
+		builder *SceneBuilder
+	}{
+		{"no camera", NewSceneBuilder()},
+		{"zero direction", NewSceneBuilder().Camera(zero, zero)},
+		{"resolution", camera().Resolution(0, 768)},
+		{"nil radius", camera().AddSphere(nil, zero, zero, white, DiffuseMaterial)},
+		{"zero radius", camera().AddSphere(NewBig0(), zero, zero, white, DiffuseMaterial)},
+		{"negative radius", camera().AddSphere(new(uint256.Int).Neg(Big1e6), zero, zero, white, DiffuseMaterial)},
+		{"huge radius", camera().AddSphere(huge, zero, zero, white, DiffuseMaterial)},
+		{"huge position", camera().AddSphere(Big1e6, Vector{huge, NewBig0(), NewBig0()}, zero, white, DiffuseMaterial)},
+		{"unset color", camera().AddSphere(Big1e6, zero, zero, Vector{}, DiffuseMaterial)},
+		{"degenerate triangle", camera().AddTriangle(NewVector(0, 0, 0), NewVector(1000000, 0, 0), NewVector(2000000, 0, 0), zero, white, SpecularMaterial)},
+		{"huge triangle", camera().AddTriangle(NewVector(0, 0, 0), Vector{NewBig0(), huge, NewBig0()}, NewVector(1000000, 0, 0), zero, white, SpecularMaterial)},
+	}
+	for _, test := range tests {
+		if _, err := test.builder.Build(0, 0); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+// TestSceneBuilderCopies checks that changing the vectors and radii passed to
+// the builder afterwards does not change the scene it builds.
+func TestSceneBuilderCopies(t *testing.T) {
+	var (
+		origin, direction = NewVector(50000000, 50000000, 295600000), NewVector(0, -42612, -1000000)
+		radius            = uint256.NewInt(16500000)
+		position, color   = NewVector(27000000, 16500000, 47000000), NewVector(999000, 999000, 999000)
+		a, b, c           = NewVector(0, 0, 0), NewVector(1000000, 0, 0), NewVector(0, 1000000, 0)
+	)
+	builder := NewSceneBuilder().
+		Camera(origin, direction).
+		AddSphere(radius, position, NewVector(0, 0, 0), color, SpecularMaterial).
+		AddTriangle(a, b, c, NewVector(0, 0, 0), color, DiffuseMaterial)
+	want, err := builder.Build(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []Vector{origin, direction, position, color, a, b, c} {
+		v.X.Add(v.X, Big1e6)
+	}
+	radius.Add(radius, Big1e6)
+	have, err := builder.Build(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatal("scene changed with the vectors passed to the builder")
+	}
+}
@@ -0,0 +1,211 @@
+package snailtracer
+
+import (
+	"sort"
+
+	"github.com/holiman/uint256"
+)
+
+// BVHThreshold is the number of primitives above which a scene is traced
+// through a bounding volume hierarchy instead of a linear scan.
+const BVHThreshold = 64
+
+const bvhLeafSize = 4
+
+type bvhPrimitive struct {
+	kind     Primitive
+	index    int
+	min, max Vector
+	centroid Vector
+}
+
+type bvhNode[T Num[T]] struct {
+	min, max    numVector[T]
+	left, right *bvhNode[T]
+	primitives  []bvhPrimitive
+}
+
+// SetBVH forces tracing through a bounding volume hierarchy on or off,
+// overriding BVHThreshold. Both paths produce bit-identical results.
+func (s *SceneOf[T]) SetBVH(enabled bool) {
+	if !enabled {
+		s.bvh = nil
+		return
+	}
+	s.bvh = newBVH(s)
+}
+
+func newBVH[T Num[T]](s *SceneOf[T]) *bvhNode[T] {
+	prims := make([]bvhPrimitive, 0, len(s.spheres)+len(s.triangles))
+	for i, sphere := range s.spheres {
+		r := Vector{sphere.radius, sphere.radius, sphere.radius}
+		prims = append(prims, newBVHPrimitive(SpherePrimitive, i, sphere.position.Sub(r), sphere.position.Add(r), s.scale))
+	}
+	for i, tri := range s.triangles {
+		min := minVector(tri.a, minVector(tri.b, tri.c))
+		max := maxVector(tri.a, maxVector(tri.b, tri.c))
+		prims = append(prims, newBVHPrimitive(TrianglePrimitive, i, min, max, s.scale))
+	}
+	if len(prims) == 0 {
+		return nil
+	}
+	return buildBVH[T](prims)
+}
+
+// newBVHPrimitive pads the bounding box of a primitive by a 1/1024 fraction of
+// its size plus the smallest hit distance of the scale, so that box tests stay
+// conservative with respect to the truncating fixed-point intersection code and
+// the not quite unit length ray directions: a primitive that the linear scan
+// would hit is never culled.
+func newBVHPrimitive(kind Primitive, index int, min, max Vector, sc *Scale) bvhPrimitive {
+	extent := max.Sub(min)
+	pad := new(uint256.Int).Set(extent.X)
+	if Cmp(extent.Y, pad) > 0 {
+		pad.Set(extent.Y)
+	}
+	if Cmp(extent.Z, pad) > 0 {
+		pad.Set(extent.Z)
+	}
+	pad.Rsh(pad, 10).Add(pad, sc.epsilon)
+	padding := Vector{pad, pad, pad}
+	return bvhPrimitive{kind, index, min.Sub(padding), max.Add(padding), min.Add(max).ScaleDiv(Big2)}
+}
+
+func buildBVH[T Num[T]](prims []bvhPrimitive) *bvhNode[T] {
+	min, max := prims[0].min, prims[0].max
+	for _, p := range prims[1:] {
+		min = minVector(min, p.min)
+		max = maxVector(max, p.max)
+	}
+	node := &bvhNode[T]{min: numVectorOf[T](min), max: numVectorOf[T](max)}
+	if len(prims) <= bvhLeafSize {
+		node.primitives = prims
+		return node
+	}
+
+	// Split at the median centroid along the longest axis of the box
+	extent := max.Sub(min)
+	axis := func(v Vector) *uint256.Int { return v.X }
+	if Cmp(extent.Y, extent.X) > 0 && Cmp(extent.Y, extent.Z) >= 0 {
+		axis = func(v Vector) *uint256.Int { return v.Y }
+	} else if Cmp(extent.Z, extent.X) > 0 {
+		axis = func(v Vector) *uint256.Int { return v.Z }
+	}
+	sort.SliceStable(prims, func(i, j int) bool {
+		return Cmp(axis(prims[i].centroid), axis(prims[j].centroid)) < 0
+	})
+	mid := len(prims) / 2
+	node.left = buildBVH[T](prims[:mid])
+	node.right = buildBVH[T](prims[mid:])
+	return node
+}
+
+// intersect returns the entry distance of the ray into the box, scaled like the
+// primitive intersection distances, and whether the ray hits the box at all.
+func (n *bvhNode[T]) intersect(r *numRay[T], sc *numScale[T]) (near T, ok bool) {
+	var far T
+	found := false
+	for i := range n.min {
+		origin, direction := r.origin[i], r.direction[i]
+		if direction.Cmp(sc.zero) == 0 {
+			if origin.Cmp(n.min[i]) < 0 || origin.Cmp(n.max[i]) > 0 {
+				return near, false
+			}
+			continue
+		}
+		t1 := n.min[i].Sub(origin).Mul(sc.one).Div(direction)
+		t2 := n.max[i].Sub(origin).Mul(sc.one).Div(direction)
+		if t1.Cmp(t2) > 0 {
+			t1, t2 = t2, t1
+		}
+		if !found || t1.Cmp(near) > 0 {
+			near = t1
+		}
+		if !found || t2.Cmp(far) < 0 {
+			far = t2
+		}
+		found = true
+	}
+	if !found {
+		// The ray has no direction, only the linear scan can decide
+		return near, true
+	}
+	// Allow for the truncation of the divisions above
+	far = far.Add(sc.two)
+	if near.Cmp(far) > 0 || far.Cmp(sc.zero) < 0 {
+		return near, false
+	}
+	return near, true
+}
+
+// traceRay finds the closest primitive hit by the ray. Ties are resolved like
+// the linear scan in SceneOf.traceRay: spheres before triangles, then by index.
+func (n *bvhNode[T]) traceRay(s *SceneOf[T], ray *numRay[T]) (dist T, p Primitive, id int) {
+	n.visit(s, ray, &dist, &p, &id)
+	return dist, p, id
+}
+
+func (n *bvhNode[T]) visit(s *SceneOf[T], ray *numRay[T], dist *T, p *Primitive, id *int) {
+	sc := s.sc
+	near, ok := n.intersect(ray, sc)
+	if !ok {
+		return
+	}
+	if (*dist).Cmp(sc.zero) > 0 {
+		// Skip boxes beyond the closest hit so far, with the same relative slack
+		limit := (*dist).Div(sc.zero.FromInt(1024)).Add(*dist).Add(sc.epsilon)
+		if near.Cmp(limit) > 0 {
+			return
+		}
+	}
+	if n.primitives == nil {
+		n.left.visit(s, ray, dist, p, id)
+		n.right.visit(s, ray, dist, p, id)
+		return
+	}
+	for _, prim := range n.primitives {
+		var d T
+		if prim.kind == SpherePrimitive {
+			d = s.numSpheres[prim.index].intersect(ray, sc, s.math)
+		} else {
+			d = s.numTriangles[prim.index].intersect(ray, sc)
+		}
+		if d.Cmp(sc.zero) <= 0 {
+			continue
+		}
+		if (*dist).Cmp(sc.zero) == 0 || d.Cmp(*dist) < 0 ||
+			(d.Cmp(*dist) == 0 && (prim.kind < *p || (prim.kind == *p && prim.index < *id))) {
+			*dist = d
+			*p = prim.kind
+			*id = prim.index
+		}
+	}
+}
+
+func minVector(v, u Vector) Vector {
+	m := v
+	if Cmp(u.X, m.X) < 0 {
+		m.X = u.X
+	}
+	if Cmp(u.Y, m.Y) < 0 {
+		m.Y = u.Y
+	}
+	if Cmp(u.Z, m.Z) < 0 {
+		m.Z = u.Z
+	}
+	return m
+}
+
+func maxVector(v, u Vector) Vector {
+	m := v
+	if Cmp(u.X, m.X) > 0 {
+		m.X = u.X
+	}
+	if Cmp(u.Y, m.Y) > 0 {
+		m.Y = u.Y
+	}
+	if Cmp(u.Z, m.Z) > 0 {
+		m.Z = u.Z
+	}
+	return m
+}
@@ -0,0 +1,110 @@
+package snailtracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// newMeshScene places an n by n grid of triangle pairs, shaped like a ridged
+// roof, inside the benchmark scene's room.
+func newMeshScene(n int) *Scene {
+	b := newBenchmarkSceneBuilder(false)
+	vertex := func(i, j int) Vector {
+		x := int64(20000000 + 60000000*i/n)
+		z := int64(40000000 + 60000000*j/n)
+		y := int64(10000000 + 4000000*((i+j)%4))
+		return NewVector(x, y, z)
+	}
+	color := NewVector(999000, 999000, 999000)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			b.AddTriangle(vertex(i, j), vertex(i+1, j), vertex(i, j+1), NewVector(0, 0, 0), color, SpecularMaterial)
+			b.AddTriangle(vertex(i+1, j), vertex(i+1, j+1), vertex(i, j+1), NewVector(0, 0, 0), color, DiffuseMaterial)
+		}
+	}
+	s, err := b.Build(0, 0)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func TestBVHBenchmarkScene(t *testing.T) {
+	linear := NewBenchmarkScene(0, 0)
+	bvh := NewBenchmarkScene(0, 0)
+	bvh.SetBVH(true)
+
+	for y := 0; y < linear.height; y += 97 {
+		for x := 0; x < linear.width; x += 101 {
+			want := linear.Trace(x, y, 2)
+			have := bvh.Trace(x, y, 2)
+			if Cmp(want.X, have.X) != 0 || Cmp(want.Y, have.Y) != 0 || Cmp(want.Z, have.Z) != 0 {
+				t.Fatalf("pixel (%d, %d): have %v, want %v", x, y, have, want)
+			}
+		}
+	}
+}
+
+func TestBVHMeshRays(t *testing.T) {
+	s := newMeshScene(12)
+	if s.bvh == nil {
+		t.Fatal("mesh scene above the threshold has no BVH")
+	}
+	rng := rand.New(rand.NewSource(1))
+	coord := func(lo, hi int64) int64 { return lo + rng.Int63n(hi-lo) }
+	for i := 0; i < 2000; i++ {
+		r := &Ray{
+			origin:    NewVec(coord(5000000, 95000000), coord(5000000, 80000000), coord(5000000, 150000000)),
+			direction: NewVec(coord(-1000000, 1000000), coord(-1000000, 1000000), coord(-1000000, 1000000)),
+		}
+		r.direction.Norm(&r.direction)
+		ray := numRayOf[Int256](r)
+		dist, p, id := s.traceRay(&ray)
+
+		bvh := s.bvh
+		s.bvh = nil
+		wantDist, wantP, wantID := s.traceRay(&ray)
+		s.bvh = bvh
+
+		if dist.Cmp(wantDist) != 0 || p != wantP || id != wantID {
+			t.Fatalf("ray %d: have (%v, %d, %d), want (%v, %d, %d)", i, &dist.v, p, id, &wantDist.v, wantP, wantID)
+		}
+	}
+}
+
+func TestBVHMeshPixels(t *testing.T) {
+	linear := newMeshScene(8)
+	linear.SetBVH(false)
+	bvh := newMeshScene(8)
+
+	for y := 0; y < linear.height; y += 151 {
+		for x := 0; x < linear.width; x += 149 {
+			want := linear.Trace(x, y, 1)
+			have := bvh.Trace(x, y, 1)
+			if Cmp(want.X, have.X) != 0 || Cmp(want.Y, have.Y) != 0 || Cmp(want.Z, have.Z) != 0 {
+				t.Fatalf("pixel (%d, %d): have %v, want %v", x, y, have, want)
+			}
+		}
+	}
+}
+
+func BenchmarkMeshSnailtracer(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		bvh  bool
+	}{{"linear", false}, {"bvh", true}} {
+		b.Run(test.name, func(b *testing.B) {
+			s := newMeshScene(32)
+			s.SetBVH(test.bvh)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				color := NewVector(0, 0, 0)
+				color = color.Add(s.Trace(512, 384, 2))
+				color = color.Add(s.Trace(325, 540, 2))
+				color = color.ScaleDiv(uint256.NewInt(2))
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package snailtracer
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/holiman/uint256"
+)
+
+// DiagnosticSite holds the statistics of the fixed-point arithmetic of one
+// operation called from one place in the tracer. Diagnostics are only
+// collected in builds with the snaildebug tag, see Diagnostics.
+type DiagnosticSite struct {
+	// Op is the instrumented operation, like "Vec.Norm" or "Sqrt".
+	Op string
+	// Caller is the file:line of the first caller outside of the arithmetic
+	// helpers in vec.go, vector.go and utils.go.
+	Caller string
+	// Calls is the number of arithmetic steps the operation made.
+	Calls uint64
+	// Overflows counts the results outside of the int256 range, which wrapped
+	// around.
+	Overflows uint64
+	// Truncations counts the divisions of a non-zero value that truncated to
+	// zero, losing every significant digit.
+	Truncations uint64
+	// MaxBits is the bit length of the largest exact result magnitude. Above
+	// 255 the result overflowed.
+	MaxBits int
+}
+
+// WriteDiagnostics writes a report of the diagnostics collected so far, the
+// sites that overflowed first. It writes nothing unless Diagnostics is set.
+func WriteDiagnostics(w io.Writer) error {
+	sites := DiagnosticSites()
+	if len(sites) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcaller\tcalls\toverflows\ttruncations\tmax bits\t")
+	for _, site := range sites {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t\n", site.Op, site.Caller, site.Calls, site.Overflows, site.Truncations, site.MaxBits)
+	}
+	return tw.Flush()
+}
+
+// diagSite collects a DiagnosticSite. Its methods perform an operation on
+// int256 values, checking it against the exact result if Diagnostics is set.
+// They accept a nil receiver, returned by diagnose in release builds.
+type diagSite struct {
+	lock sync.Mutex
+	DiagnosticSite
+}
+
+// The int256 range.
+var (
+	minInt256 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255))
+	maxInt256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+)
+
+// toBig returns x as a signed integer.
+func toBig(x *uint256.Int) *big.Int {
+	b := x.ToBig()
+	if x.Sign() < 0 {
+		b.Sub(b, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return b
+}
+
+// check records the exact result of an operation.
+func (d *diagSite) check(exact *big.Int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.Calls++
+	if exact.Cmp(minInt256) < 0 || exact.Cmp(maxInt256) > 0 {
+		d.Overflows++
+	}
+	if bits := new(big.Int).Abs(exact).BitLen(); bits > d.MaxBits {
+		d.MaxBits = bits
+	}
+}
+
+func (d *diagSite) checkAdd(x, y *uint256.Int) {
+	d.check(new(big.Int).Add(toBig(x), toBig(y)))
+}
+
+func (d *diagSite) checkSub(x, y *uint256.Int) {
+	d.check(new(big.Int).Sub(toBig(x), toBig(y)))
+}
+
+func (d *diagSite) checkMul(x, y *uint256.Int) {
+	d.check(new(big.Int).Mul(toBig(x), toBig(y)))
+}
+
+func (d *diagSite) checkSDiv(x, y *uint256.Int) {
+	if y.IsZero() {
+		d.check(new(big.Int))
+		return
+	}
+	q := new(big.Int).Quo(toBig(x), toBig(y))
+	d.check(q)
+	if q.Sign() == 0 && !x.IsZero() {
+		d.lock.Lock()
+		d.Truncations++
+		d.lock.Unlock()
+	}
+}
+
+func (d *diagSite) add(z, x, y *uint256.Int) *uint256.Int {
+	if Diagnostics && d != nil {
+		d.checkAdd(x, y)
+	}
+	return z.Add(x, y)
+}
+
+func (d *diagSite) sub(z, x, y *uint256.Int) *uint256.Int {
+	if Diagnostics && d != nil {
+		d.checkSub(x, y)
+	}
+	return z.Sub(x, y)
+}
+
+func (d *diagSite) mul(z, x, y *uint256.Int) *uint256.Int {
+	if Diagnostics && d != nil {
+		d.checkMul(x, y)
+	}
+	return z.Mul(x, y)
+}
+
+func (d *diagSite) sdiv(z, x, y *uint256.Int) *uint256.Int {
+	if Diagnostics && d != nil {
+		d.checkSDiv(x, y)
+	}
+	return z.SDiv(x, y)
+}
+
+func (d *diagSite) neg(z, x *uint256.Int) *uint256.Int {
+	if Diagnostics && d != nil {
+		d.check(new(big.Int).Neg(toBig(x)))
+	}
+	return z.Neg(x)
+}
+
+// value records x, the argument or result of an operation without arithmetic.
+func (d *diagSite) value(x *uint256.Int) {
+	if Diagnostics && d != nil {
+		d.check(toBig(x))
+	}
+}
+
+// The diag functions perform an operation on values of the representation T
+// like the diagSite methods, checking it if T is Int256.
+
+func diagAdd[T Num[T]](d *diagSite, x, y T) T {
+	if Diagnostics && d != nil {
+		if x, ok := any(x).(Int256); ok {
+			y := any(y).(Int256)
+			d.checkAdd(&x.v, &y.v)
+		}
+	}
+	return x.Add(y)
+}
+
+func diagSub[T Num[T]](d *diagSite, x, y T) T {
+	if Diagnostics && d != nil {
+		if x, ok := any(x).(Int256); ok {
+			y := any(y).(Int256)
+			d.checkSub(&x.v, &y.v)
+		}
+	}
+	return x.Sub(y)
+}
+
+func diagMul[T Num[T]](d *diagSite, x, y T) T {
+	if Diagnostics && d != nil {
+		if x, ok := any(x).(Int256); ok {
+			y := any(y).(Int256)
+			d.checkMul(&x.v, &y.v)
+		}
+	}
+	return x.Mul(y)
+}
+
+func diagDiv[T Num[T]](d *diagSite, x, y T) T {
+	if Diagnostics && d != nil {
+		if x, ok := any(x).(Int256); ok {
+			y := any(y).(Int256)
+			d.checkSDiv(&x.v, &y.v)
+		}
+	}
+	return x.Div(y)
+}
+
+func diagValue[T Num[T]](d *diagSite, x T) {
+	if Diagnostics && d != nil {
+		if x, ok := any(x).(Int256); ok {
+			d.value(&x.v)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build snaildebug
+
+package snailtracer
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Diagnostics reports whether the fixed-point arithmetic of the tracer is
+// checked for overflows and truncations, in builds with the snaildebug tag.
+const Diagnostics = true
+
+var (
+	diagLock  sync.Mutex
+	diagSites = make(map[string]*diagSite)
+)
+
+// diagHelpers are the files whose functions are attributed to their caller.
+var diagHelpers = map[string]bool{
+	"vec.go":         true,
+	"vector.go":      true,
+	"utils.go":       true,
+	"diagnostics.go": true,
+}
+
+// diagnose returns the site collecting the diagnostics of op, called from the
+// first caller outside of the arithmetic helpers.
+func diagnose(op string) *diagSite {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	caller := "?"
+	for {
+		frame, more := frames.Next()
+		file := filepath.Base(frame.File)
+		if !diagHelpers[file] || !strings.Contains(frame.Function, "/snailtracer.") {
+			caller = fmt.Sprintf("%s:%d", file, frame.Line)
+			break
+		}
+		if !more {
+			break
+		}
+	}
+
+	key := op + " " + caller
+	diagLock.Lock()
+	defer diagLock.Unlock()
+	site, ok := diagSites[key]
+	if !ok {
+		site = &diagSite{DiagnosticSite: DiagnosticSite{Op: op, Caller: caller}}
+		diagSites[key] = site
+	}
+	return site
+}
+
+// DiagnosticSites returns the diagnostics collected so far, the sites that
+// overflowed first, then the ones with the largest magnitudes.
+func DiagnosticSites() []DiagnosticSite {
+	diagLock.Lock()
+	sites := make([]DiagnosticSite, 0, len(diagSites))
+	for _, site := range diagSites {
+		site.lock.Lock()
+		sites = append(sites, site.DiagnosticSite)
+		site.lock.Unlock()
+	}
+	diagLock.Unlock()
+	sort.Slice(sites, func(i, j int) bool {
+		a, b := sites[i], sites[j]
+		if a.Overflows != b.Overflows {
+			return a.Overflows > b.Overflows
+		}
+		if a.MaxBits != b.MaxBits {
+			return a.MaxBits > b.MaxBits
+		}
+		if a.Op != b.Op {
+			return a.Op < b.Op
+		}
+		return a.Caller < b.Caller
+	})
+	return sites
+}
+
+// ResetDiagnostics discards the diagnostics collected so far.
+func ResetDiagnostics() {
+	diagLock.Lock()
+	defer diagLock.Unlock()
+	diagSites = make(map[string]*diagSite)
+}
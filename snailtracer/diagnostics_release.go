@@ -0,0 +1,20 @@
+//go:build !snaildebug
+
+package snailtracer
+
+// Diagnostics reports whether the fixed-point arithmetic of the tracer is
+// checked for overflows and truncations, in builds with the snaildebug tag.
+const Diagnostics = false
+
+func diagnose(op string) *diagSite {
+	return nil
+}
+
+// DiagnosticSites returns the diagnostics collected so far, none without the
+// snaildebug tag.
+func DiagnosticSites() []DiagnosticSite {
+	return nil
+}
+
+// ResetDiagnostics discards the diagnostics collected so far.
+func ResetDiagnostics() {}
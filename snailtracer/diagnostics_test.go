@@ -0,0 +1,78 @@
+package snailtracer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+func TestDiagnosticSiteChecks(t *testing.T) {
+	var (
+		one    = uint256.NewInt(1)
+		three  = uint256.NewInt(3)
+		big100 = new(uint256.Int).Lsh(one, 100)
+		big200 = new(uint256.Int).Lsh(one, 200)
+		maxInt = new(uint256.Int).Sub(new(uint256.Int).Lsh(one, 255), one)
+		minInt = new(uint256.Int).Lsh(one, 255)
+		negOne = new(uint256.Int).Neg(one)
+	)
+	tests := []struct {
+		name        string
+		check       func(d *diagSite)
+		overflows   uint64
+		truncations uint64
+		maxBits     int
+	}{
+		{"add", func(d *diagSite) { d.checkAdd(big100, big200) }, 0, 0, 201},
+		{"add overflow", func(d *diagSite) { d.checkAdd(maxInt, one) }, 1, 0, 256},
+		{"sub overflow", func(d *diagSite) { d.checkSub(minInt, one) }, 1, 0, 256},
+		{"sub negative", func(d *diagSite) { d.checkSub(one, big100) }, 0, 0, 100},
+		{"mul overflow", func(d *diagSite) { d.checkMul(big100, big200) }, 1, 0, 301},
+		{"mul negative", func(d *diagSite) { d.checkMul(negOne, big200) }, 0, 0, 201},
+		{"sdiv", func(d *diagSite) { d.checkSDiv(big100, three) }, 0, 0, 99},
+		{"sdiv truncation", func(d *diagSite) { d.checkSDiv(one, three) }, 0, 1, 0},
+		{"sdiv overflow", func(d *diagSite) { d.checkSDiv(minInt, negOne) }, 1, 0, 256},
+		{"sdiv by zero", func(d *diagSite) { d.checkSDiv(one, new(uint256.Int)) }, 0, 0, 0},
+	}
+	for _, test := range tests {
+		d := new(diagSite)
+		test.check(d)
+		if d.Calls != 1 || d.Overflows != test.overflows || d.Truncations != test.truncations || d.MaxBits != test.maxBits {
+			t.Errorf("%s: have %d calls, %d overflows, %d truncations, %d bits, want 1, %d, %d, %d",
+				test.name, d.Calls, d.Overflows, d.Truncations, d.MaxBits, test.overflows, test.truncations, test.maxBits)
+		}
+	}
+}
+
+// TestDiagnostics traces a scene with diagnostics, in builds with the
+// snaildebug tag.
+func TestDiagnostics(t *testing.T) {
+	if !Diagnostics {
+		t.Skip("diagnostics need the snaildebug build tag")
+	}
+	ResetDiagnostics()
+	defer ResetDiagnostics()
+	newLowResScene(t, 8, 6).TraceImage(1)
+
+	var fresnel bool
+	for _, site := range DiagnosticSites() {
+		if site.Overflows > 0 {
+			t.Errorf("%s at %s overflowed %d times", site.Op, site.Caller, site.Overflows)
+		}
+		if site.Op == "fresnel" && strings.HasPrefix(site.Caller, "tracer.go:") {
+			fresnel = site.MaxBits > 0
+		}
+	}
+	if !fresnel {
+		t.Error("no diagnostics for the Fresnel term in tracer.go")
+	}
+	var report bytes.Buffer
+	if err := WriteDiagnostics(&report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "overflows") {
+		t.Errorf("report has no header:\n%s", report.String())
+	}
+}
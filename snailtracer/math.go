@@ -0,0 +1,199 @@
+package snailtracer
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/holiman/uint256"
+)
+
+// MathMode selects the implementations of the square root, sine and cosine a
+// Scene traces with. The zero value, ContractMath, is the contract's.
+type MathMode int
+
+// ContractMath is the contract's Babylonian square root starting from (x+1)/2
+// and Taylor series sine, tracing the same pixels as the EVM.
+const ContractMath MathMode = 0
+
+const (
+	// FastSqrt runs Newton's method from a power of two above the root, or a
+	// float64 estimate corrected to the exact root for 64-bit arguments. It
+	// returns the same roots as the contract for every int256 but 2^255-1,
+	// where the contract's (x+1)/2 overflows; the benchmark never gets there,
+	// so it traces the same pixels as the EVM.
+	FastSqrt MathMode = 1 << iota
+
+	// FastTrig looks the sine and cosine up in a table of 4096 angles per
+	// period, corrected by a third order polynomial for the remaining angle.
+	// Both are within 2 units of the exact value at every scale. The
+	// contract's sine is within 5 units, so they often differ by a few units,
+	// but its cosine, sqrt(1 - sin²), loses up to half of the digits near ±π/2
+	// and is negative where the sine rounds beyond ±1. A few pixels differ.
+	FastTrig
+
+	// FastMath combines FastSqrt and FastTrig.
+	FastMath = FastSqrt | FastTrig
+)
+
+func (m MathMode) String() string {
+	switch m {
+	case ContractMath:
+		return "contract"
+	case FastSqrt:
+		return "fastsqrt"
+	case FastTrig:
+		return "fasttrig"
+	case FastMath:
+		return "fast"
+	}
+	return fmt.Sprintf("MathMode(%d)", int(m))
+}
+
+// SetMath selects the square root, sine and cosine the scene traces with,
+// ContractMath by default.
+func (s *SceneOf[T]) SetMath(m MathMode) {
+	s.math = m
+}
+
+// Math returns the math mode the scene traces with.
+func (s *SceneOf[T]) Math() MathMode {
+	return s.math
+}
+
+func (m MathMode) sqrt(x *uint256.Int) uint256.Int {
+	if m&FastSqrt != 0 {
+		return fastSqrt(x)
+	}
+	return sqrt(x)
+}
+
+// sin and cos reduce x in place to [0, 2π), like Sin.
+func (m MathMode) sin(x *uint256.Int, sc *Scale) uint256.Int {
+	if m&FastTrig != 0 {
+		return fastSin(x, sc)
+	}
+	return sin(x, sc)
+}
+
+func (m MathMode) cos(x *uint256.Int, sc *Scale) uint256.Int {
+	if m&FastTrig != 0 {
+		return fastCos(x, sc)
+	}
+	return cos(x, sc)
+}
+
+// fastSqrt is the FastSqrt square root, without allocating. Like the
+// contract's, the root of a negative x is x.
+func fastSqrt(x *uint256.Int) (y uint256.Int) {
+	d := diagnose("FastSqrt")
+	d.value(x)
+	if x.Sign() <= 0 {
+		return *x
+	}
+	if x.IsUint64() {
+		n := x.Uint64()
+		r := uint64(math.Sqrt(float64(n)))
+		// The float64 estimate is off by at most one either way
+		for hi, lo := bits.Mul64(r, r); hi > 0 || lo > n; hi, lo = bits.Mul64(r, r) {
+			r--
+		}
+		for hi, lo := bits.Mul64(r+1, r+1); hi == 0 && lo <= n; hi, lo = bits.Mul64(r+1, r+1) {
+			r++
+		}
+		y.SetUint64(r)
+		return y
+	}
+	var z uint256.Int
+	y.Lsh(Big1, uint(x.BitLen()+1)/2)
+	for {
+		z.Div(x, &y)
+		z.Add(&z, &y).Rsh(&z, 1)
+		if z.Cmp(&y) >= 0 {
+			return y
+		}
+		y = z
+	}
+}
+
+// trigTableSize is the number of angles per period of the FastTrig tables.
+const trigTableSize = 4096
+
+// trigTable holds the sine and cosine of the angles k*2π/trigTableSize of a
+// scale, where 2π is the scale's, at that scale. It is built on first use.
+type trigTable struct {
+	once     sync.Once
+	sin, cos []int64
+}
+
+func (sc *Scale) trigTable() *trigTable {
+	t := &sc.trig
+	t.once.Do(func() {
+		one := float64(sc.one.Uint64())
+		period := float64(sc.twoPi.Uint64()) / one
+		t.sin = make([]int64, trigTableSize)
+		t.cos = make([]int64, trigTableSize)
+		for k := range t.sin {
+			angle := period * float64(k) / trigTableSize
+			t.sin[k] = int64(math.Round(math.Sin(angle) * one))
+			t.cos[k] = int64(math.Round(math.Cos(angle) * one))
+		}
+	})
+	return t
+}
+
+var (
+	bigTrigTableSize = uint256.NewInt(trigTableSize)
+	big6             = uint256.NewInt(6)
+)
+
+// fastTrig reduces x in place to [0, 2π) and returns its sine and cosine.
+func fastTrig(x *uint256.Int, sc *Scale) (sin, cos uint256.Int) {
+	d := diagnose("FastTrig")
+	for x.Sign() < 0 {
+		d.add(x, x, sc.twoPi)
+	}
+	for Cmp(x, sc.twoPi) >= 0 {
+		d.sub(x, x, sc.twoPi)
+	}
+	t := sc.trigTable()
+
+	// x is the table angle k*2π/N plus b, with b*N = rem in [0, 2π)
+	var k, rem, t2 uint256.Int
+	rem.Mul(x, bigTrigTableSize)
+	k.Div(&rem, sc.twoPi)
+	rem.Sub(&rem, t2.Mul(&k, sc.twoPi))
+	i := k.Uint64()
+
+	// sin b = b - b³/6 and cos b = 1 - b²/2, exact to 1e-13 as b < 2π/N
+	var sinB, cosB, u, v, n uint256.Int
+	n.Mul(bigTrigTableSize, sc.one)
+	u.Mul(&rem, &rem).Div(&u, &n)
+	cosB.Div(&u, bigTrigTableSize).Rsh(&cosB, 1)
+	cosB.Sub(sc.one, &cosB)
+	v.Mul(&u, &rem).Div(&v, &n).Div(&v, bigTrigTableSize).Div(&v, big6)
+	sinB.Div(&rem, bigTrigTableSize).Sub(&sinB, &v)
+
+	// sin(a+b) = sin a cos b + cos a sin b, cos(a+b) = cos a cos b - sin a sin b
+	var sinA, cosA uint256.Int
+	setInt64(&sinA, t.sin[i])
+	setInt64(&cosA, t.cos[i])
+	sin.Mul(&sinA, &cosB).Add(&sin, u.Mul(&cosA, &sinB)).SDiv(&sin, sc.one)
+	cos.Mul(&cosA, &cosB).Sub(&cos, u.Mul(&sinA, &sinB)).SDiv(&cos, sc.one)
+	return sin, cos
+}
+
+// fastSin is the FastTrig sine at the scale sc, without allocating. It reduces
+// x in place too.
+func fastSin(x *uint256.Int, sc *Scale) uint256.Int {
+	sin, _ := fastTrig(x, sc)
+	return sin
+}
+
+// fastCos is the FastTrig cosine at the scale sc, without allocating. Like the
+// contract's sqrt(1 - sin²), it returns the magnitude of the cosine.
+func fastCos(x *uint256.Int, sc *Scale) uint256.Int {
+	_, cos := fastTrig(x, sc)
+	return abs256(&cos)
+}
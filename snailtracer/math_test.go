@@ -0,0 +1,227 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// maxInt256Sqrt is 2^255-1, the only int256 whose contract square root is
+// wrong.
+var maxInt256Sqrt = new(uint256.Int).Sub(new(uint256.Int).Lsh(Big1, 255), Big1)
+
+// checkFastSqrt checks that fastSqrt returns the contract's root of x, or the
+// exact one for 2^255-1.
+func checkFastSqrt(t *testing.T, x *uint256.Int) {
+	t.Helper()
+	have, want := fastSqrt(x), sqrt(x)
+	if x.Eq(maxInt256Sqrt) {
+		// The contract's (x+1)/2 overflows into a negative guess
+		want.SetFromBig(new(big.Int).Sqrt(x.ToBig()))
+	}
+	if !have.Eq(&want) {
+		t.Errorf("sqrt(%s): have %s, want %s", x.Hex(), have.Hex(), want.Hex())
+	}
+}
+
+// TestFastSqrt checks fastSqrt against the contract's square root on every
+// argument below 2^18, around every square below 2^32, around powers of two
+// and on negative arguments, which are their own root.
+func TestFastSqrt(t *testing.T) {
+	var x uint256.Int
+	for n := uint64(0); n < 1<<18; n++ {
+		checkFastSqrt(t, x.SetUint64(n))
+	}
+	for k := uint64(1 << 9); k < 1<<32; k += k/64 + 1 {
+		for _, n := range []uint64{k*k - 1, k * k, k*k + 1} {
+			checkFastSqrt(t, x.SetUint64(n))
+		}
+	}
+	for shift := uint(0); shift < 256; shift++ {
+		p := new(uint256.Int).Lsh(Big1, shift)
+		checkFastSqrt(t, p)
+		checkFastSqrt(t, x.Sub(p, Big1))
+		checkFastSqrt(t, x.Add(p, Big1))
+	}
+	checkFastSqrt(t, maxInt256Sqrt)
+	if have := sqrt(maxInt256Sqrt); have.Sign() >= 0 {
+		t.Errorf("contract sqrt(2^255-1) is %s, no longer negative", have.Hex())
+	}
+}
+
+func FuzzFastSqrt(f *testing.F) {
+	f.Add(uint64(0), uint64(0), uint64(0), uint64(0))
+	f.Add(uint64(1e12), uint64(0), uint64(0), uint64(0))
+	f.Add(uint64(math.MaxUint64), uint64(math.MaxUint64), uint64(math.MaxUint64), uint64(math.MaxInt64))
+	f.Fuzz(func(t *testing.T, a, b, c, d uint64) {
+		checkFastSqrt(t, &uint256.Int{a, b, c, d})
+	})
+}
+
+// trigErrors returns the distances, in units of the scale, of the sine
+// and cosine of x from the float64 reference, the cosine taken in magnitude
+// like the contract's. ok is false where the sine rounds beyond ±1 and the
+// contract's cosine is meaningless.
+func trigErrors(x uint64, sc *Scale, sin, cos func(*uint256.Int, *Scale) uint256.Int) (sinErr, cosErr int64, ok bool) {
+	one := float64(sc.one.Uint64())
+	var y uint256.Int
+	s := sin(y.SetUint64(x), sc)
+	c := cos(y.SetUint64(x), sc)
+	sinErr = int64(math.Abs(float64(toInt64(&s))-math.Sin(float64(x)/one)*one) + 0.5)
+	cosErr = int64(math.Abs(float64(toInt64(&c))-math.Abs(math.Cos(float64(x)/one))*one) + 0.5)
+	return sinErr, cosErr, abs(toInt64(&s)) <= int64(one)
+}
+
+// TestFastTrig documents how FastTrig and the contract's series compare to the
+// exact sine and cosine over a period sampled at every scale: FastTrig is
+// within 2 units, the contract's sine within 5, and its cosine off by a few
+// times the square root of the scale around ±π/2, and negative where its sine
+// rounds beyond ±1.
+func TestFastTrig(t *testing.T) {
+	for digits := MinScaleDigits; digits <= MaxScaleDigits; digits++ {
+		sc := MustScale(digits)
+		period := sc.twoPi.Uint64()
+		step := period/20000 + 1
+		var fastSinErr, fastCosErr, sinErr, cosErr, beyond int64
+		for x := uint64(0); x < period; x += step {
+			s, c, _ := trigErrors(x, sc, FastTrig.sin, FastTrig.cos)
+			fastSinErr, fastCosErr = maxInt64(fastSinErr, s), maxInt64(fastCosErr, c)
+			s, c, ok := trigErrors(x, sc, sin, cos)
+			sinErr = maxInt64(sinErr, s)
+			if !ok {
+				beyond++
+				if v := cos(new(uint256.Int).SetUint64(x), sc); v.Sign() >= 0 {
+					t.Errorf("1e%d: contract cos(%d) is %d beyond ±1, not negative", digits, x, toInt64(&v))
+				}
+				continue
+			}
+			cosErr = maxInt64(cosErr, c)
+		}
+		t.Logf("1e%d: fast sin ±%d cos ±%d, contract sin ±%d cos ±%d, %d sines beyond ±1", digits, fastSinErr, fastCosErr, sinErr, cosErr, beyond)
+		if fastSinErr > 2 || fastCosErr > 2 {
+			t.Errorf("1e%d: fast sin off by %d, cos by %d", digits, fastSinErr, fastCosErr)
+		}
+		if sinErr > 5 {
+			t.Errorf("1e%d: contract sin off by %d", digits, sinErr)
+		}
+		if root := int64(math.Sqrt(float64(sc.one.Uint64()))); cosErr > 4*root {
+			t.Errorf("1e%d: contract cos off by %d", digits, cosErr)
+		}
+	}
+}
+
+func maxInt64(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// FuzzFastSin checks that the FastTrig sine stays within 7 units of the
+// contract's at 1e6, for angles reduced from up to a thousand periods.
+func FuzzFastSin(f *testing.F) {
+	f.Add(int64(0))
+	f.Add(int64(1570796))
+	f.Add(int64(-6283184))
+	f.Fuzz(func(t *testing.T, x int64) {
+		x %= 1000 * 6283184
+		var a, b uint256.Int
+		setInt64(&a, x)
+		setInt64(&b, x)
+		have, want := fastSin(&a, DefaultScale), sin(&b, DefaultScale)
+		if !a.Eq(&b) {
+			t.Fatalf("sin(%d): reduced to %d, want %d", x, toInt64(&a), toInt64(&b))
+		}
+		if d := abs(toInt64(&have) - toInt64(&want)); d > 7 {
+			t.Errorf("sin(%d): have %d, want %d", x, toInt64(&have), toInt64(&want))
+		}
+	})
+}
+
+// TestMathModes checks that FastSqrt traces the contract's pixels and logs how
+// far FastTrig is from them and from the float64 reference.
+func TestMathModes(t *testing.T) {
+	s := NewBenchmarkScene(0, 0)
+	fast := NewBenchmarkScene(0, 0)
+	fast.SetMath(FastSqrt)
+	for _, p := range BenchmarkPixels {
+		r, g, b := fast.TracePixel(p.X, p.Y, p.SPP)
+		wr, wg, wb := s.TracePixel(p.X, p.Y, p.SPP)
+		if r != wr || g != wg || b != wb {
+			t.Errorf("pixel (%d, %d): have %d %d %d, want %d %d %d", p.X, p.Y, r, g, b, wr, wg, wb)
+		}
+	}
+
+	s = newLowResScene(t, 32, 24)
+	want := s.TraceImage(2)
+	reference := NewSceneOf[Float64](s).TraceImage(2)
+	for _, mode := range []MathMode{FastSqrt, FastTrig, FastMath} {
+		s.SetMath(mode)
+		img := s.TraceImage(2)
+		if mode == FastSqrt && !bytes.Equal(img, want) {
+			t.Errorf("%v: image differs from the contract's", mode)
+		}
+		t.Logf("%v: %d of %d pixels differ from the contract, %d from float64", mode, differingPixels(img, want), len(want)/3, differingPixels(img, reference))
+	}
+	t.Logf("contract: %d of %d pixels differ from float64", differingPixels(want, reference), len(want)/3)
+	if have := s.Rescale(MustScale(9)).Math(); have != FastMath {
+		t.Errorf("rescaled scene traces with %v, want %v", have, FastMath)
+	}
+}
+
+func differingPixels(img, want []byte) int {
+	var n int
+	for i := 0; i < len(want); i += 3 {
+		if !bytes.Equal(img[i:i+3], want[i:i+3]) {
+			n++
+		}
+	}
+	return n
+}
+
+// BenchmarkMath compares the contract's square root, sine and cosine with the
+// fast ones, on arguments like the tracer's.
+func BenchmarkMath(b *testing.B) {
+	sq := uint256.NewInt(123456789012) // A dot product of unit vectors at 1e6
+	angle := uint256.NewInt(4712389)   // 3π/2, where the series is longest
+	for _, mode := range []MathMode{ContractMath, FastMath} {
+		b.Run("sqrt/"+mode.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mode.sqrt(sq)
+			}
+		})
+		b.Run("sin/"+mode.String(), func(b *testing.B) {
+			var x uint256.Int
+			for i := 0; i < b.N; i++ {
+				mode.sin(x.Set(angle), DefaultScale)
+			}
+		})
+		b.Run("cos/"+mode.String(), func(b *testing.B) {
+			var x uint256.Int
+			for i := 0; i < b.N; i++ {
+				mode.cos(x.Set(angle), DefaultScale)
+			}
+		})
+	}
+}
+
+// BenchmarkMathModes traces the benchmark pixels in every math mode. The
+// backends package benchmarks the Wasm backends in the same modes.
+func BenchmarkMathModes(b *testing.B) {
+	for _, mode := range []MathMode{ContractMath, FastSqrt, FastTrig, FastMath} {
+		b.Run(mode.String(), func(b *testing.B) {
+			s := NewBenchmarkScene(0, 0)
+			s.SetMath(mode)
+			for i := 0; i < b.N; i++ {
+				for _, p := range BenchmarkPixels {
+					s.TracePixel(p.X, p.Y, p.SPP)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package snailtracer
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/holiman/uint256"
+)
+
+// Num is a number representation the tracer, SceneOf, can run on. Values are
+// immutable and hold fixed-point numbers at the scale of the scene, like the
+// contract's int256 values.
+type Num[T any] interface {
+	// FromInt returns n in the representation. It is called on the zero value.
+	FromInt(n int64) T
+	// Int returns the value truncated to an int64.
+	Int() int64
+	Add(y T) T
+	Sub(y T) T
+	Mul(y T) T
+	// Div divides rounding towards zero; division by zero is zero, like in the
+	// EVM.
+	Div(y T) T
+	// Mod returns the remainder with the sign of the dividend; modulus zero is
+	// zero, like in the EVM.
+	Mod(y T) T
+	Neg() T
+	Cmp(y T) int
+	// Sqrt returns the square root computed like m's, a fixed-point value if
+	// the argument has twice the decimals of the scale.
+	Sqrt(m MathMode) T
+	// Sin returns the sine computed like m's of a fixed-point angle in radians
+	// at the scale sc.
+	Sin(sc *Scale, m MathMode) T
+	// Cos returns the magnitude of the cosine computed like m's, like Sin.
+	Cos(sc *Scale, m MathMode) T
+}
+
+// Int256 is the contract's int256 representation, a two's complement 256-bit
+// integer. Scene traces on it, computing the same pixels as the contract.
+type Int256 struct {
+	v uint256.Int
+}
+
+func (Int256) FromInt(n int64) Int256 {
+	var z Int256
+	z.v.SetUint64(uint64(abs(n)))
+	if n < 0 {
+		z.v.Neg(&z.v)
+	}
+	return z
+}
+
+func (x Int256) Int() int64 { return int64(x.v.Uint64()) }
+
+func (x Int256) Add(y Int256) Int256 { x.v.Add(&x.v, &y.v); return x }
+func (x Int256) Sub(y Int256) Int256 { x.v.Sub(&x.v, &y.v); return x }
+func (x Int256) Mul(y Int256) Int256 { x.v.Mul(&x.v, &y.v); return x }
+func (x Int256) Div(y Int256) Int256 { x.v.SDiv(&x.v, &y.v); return x }
+func (x Int256) Mod(y Int256) Int256 { x.v.SMod(&x.v, &y.v); return x }
+func (x Int256) Neg() Int256         { x.v.Neg(&x.v); return x }
+
+func (x Int256) Cmp(y Int256) int {
+	switch {
+	case x.v.Slt(&y.v):
+		return -1
+	case x.v.Sgt(&y.v):
+		return 1
+	}
+	return 0
+}
+
+func (x Int256) Sqrt(m MathMode) Int256 {
+	return Int256{m.sqrt(&x.v)}
+}
+
+func (x Int256) Sin(sc *Scale, m MathMode) Int256 {
+	return Int256{m.sin(&x.v, sc)}
+}
+
+func (x Int256) Cos(sc *Scale, m MathMode) Int256 {
+	return Int256{m.cos(&x.v, sc)}
+}
+
+// Int64 is a fixed-point representation in 64-bit integers. Intermediates that
+// the contract keeps in 256 bits, like the powers in Sin or in the refractive
+// Fresnel term, wrap around.
+type Int64 int64
+
+func (Int64) FromInt(n int64) Int64 { return Int64(n) }
+
+func (x Int64) Int() int64 { return int64(x) }
+
+func (x Int64) Add(y Int64) Int64 { return x + y }
+func (x Int64) Sub(y Int64) Int64 { return x - y }
+func (x Int64) Mul(y Int64) Int64 { return x * y }
+func (x Int64) Neg() Int64        { return -x }
+
+func (x Int64) Div(y Int64) Int64 {
+	if y == 0 {
+		return 0
+	}
+	return x / y
+}
+
+func (x Int64) Mod(y Int64) Int64 {
+	if y == 0 {
+		return 0
+	}
+	return x % y
+}
+
+func (x Int64) Cmp(y Int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (x Int64) Sqrt(m MathMode) Int64 {
+	if m&FastSqrt != 0 && x > 0 {
+		// The float64 estimate is off by at most one either way
+		n := uint64(x)
+		r := uint64(math.Sqrt(float64(n)))
+		for hi, lo := bits.Mul64(r, r); hi > 0 || lo > n; hi, lo = bits.Mul64(r, r) {
+			r--
+		}
+		for hi, lo := bits.Mul64(r+1, r+1); hi == 0 && lo <= n; hi, lo = bits.Mul64(r+1, r+1) {
+			r++
+		}
+		return Int64(r)
+	}
+	return intSqrt(x)
+}
+
+func (x Int64) Sin(sc *Scale, m MathMode) Int64 {
+	if m&FastTrig != 0 {
+		sin, _ := intFastTrig(x, sc)
+		return sin
+	}
+	return intSin(x, sc)
+}
+
+func (x Int64) Cos(sc *Scale, m MathMode) Int64 {
+	if m&FastTrig != 0 {
+		_, cos := intFastTrig(x, sc)
+		if cos < 0 {
+			return -cos
+		}
+		return cos
+	}
+	return intCos(x, sc, m)
+}
+
+// Float64 is the floating-point reference representation. It keeps the
+// fixed-point scale so that the tracer runs unchanged, but divides without
+// truncating and computes square roots and sines exactly, in every math mode.
+type Float64 float64
+
+func (Float64) FromInt(n int64) Float64 { return Float64(n) }
+
+func (x Float64) Int() int64 { return int64(x) }
+
+func (x Float64) Add(y Float64) Float64 { return x + y }
+func (x Float64) Sub(y Float64) Float64 { return x - y }
+func (x Float64) Mul(y Float64) Float64 { return x * y }
+func (x Float64) Neg() Float64          { return -x }
+
+func (x Float64) Div(y Float64) Float64 {
+	if y == 0 {
+		return 0
+	}
+	return x / y
+}
+
+func (x Float64) Mod(y Float64) Float64 {
+	if y == 0 {
+		return 0
+	}
+	return Float64(math.Mod(float64(x), float64(y)))
+}
+
+func (x Float64) Cmp(y Float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (x Float64) Sqrt(MathMode) Float64 { return Float64(math.Sqrt(float64(x))) }
+
+func (x Float64) Sin(sc *Scale, _ MathMode) Float64 {
+	one := float64(sc.one.Uint64())
+	return Float64(one * math.Sin(float64(x)/one))
+}
+
+func (x Float64) Cos(sc *Scale, m MathMode) Float64 {
+	return intCos(x, sc, m)
+}
+
+// numOf converts x, a signed int256, to T. Representations other than Int256
+// take its low 64 bits.
+func numOf[T Num[T]](x *uint256.Int) T {
+	var n T
+	if z, ok := any(&n).(*Int256); ok {
+		z.v.Set(x)
+		return n
+	}
+	return n.FromInt(int64(x.Uint64()))
+}
+
+// intSqrt is the contract's square root for integer representations.
+func intSqrt[T Num[T]](x T) T {
+	one, two := x.FromInt(1), x.FromInt(2)
+	z := x.Add(one).Div(two)
+	y := x
+	for z.Cmp(y) < 0 {
+		y = z
+		z = x.Div(y).Add(y).Div(two)
+	}
+	return y
+}
+
+// intSin is the contract's sine at the scale sc for integer representations.
+func intSin[T Num[T]](x T, sc *Scale) T {
+	var (
+		zero, one, two = x.FromInt(0), x.FromInt(1), x.FromInt(2)
+		scale          = numOf[T](sc.one)
+		twoPi          = numOf[T](sc.twoPi)
+	)
+	for x.Cmp(zero) < 0 {
+		x = x.Add(twoPi)
+	}
+	for x.Cmp(twoPi) >= 0 {
+		x = x.Sub(twoPi)
+	}
+
+	n, y, s, d, f := x, zero, one, one, two
+	for n.Cmp(d) > 0 {
+		y = y.Add(s.Mul(n).Div(d))
+		n = n.Mul(x).Mul(x).Div(scale).Div(scale)
+		d = d.Mul(f).Mul(f.Add(one))
+		s = s.Neg()
+		f = f.Add(two)
+	}
+	return y
+}
+
+// intCos is the contract's cosine, sqrt(1 - sin²), at the scale sc.
+func intCos[T Num[T]](x T, sc *Scale, m MathMode) T {
+	s := x.Sin(sc, m)
+	one := numOf[T](sc.one)
+	return one.Mul(one).Sub(s.Mul(s)).Sqrt(m)
+}
+
+// intFastTrig is the FastTrig sine and cosine at the scale sc for integer
+// representations.
+func intFastTrig[T Num[T]](x T, sc *Scale) (sin, cos T) {
+	var (
+		zero, two, six = x.FromInt(0), x.FromInt(2), x.FromInt(6)
+		one            = numOf[T](sc.one)
+		twoPi          = numOf[T](sc.twoPi)
+		size           = x.FromInt(trigTableSize)
+	)
+	for x.Cmp(zero) < 0 {
+		x = x.Add(twoPi)
+	}
+	for x.Cmp(twoPi) >= 0 {
+		x = x.Sub(twoPi)
+	}
+	t := sc.trigTable()
+
+	// x is the table angle k*2π/N plus b, with b*N = rem in [0, 2π)
+	rem := x.Mul(size)
+	k := rem.Div(twoPi)
+	rem = rem.Sub(k.Mul(twoPi))
+
+	// sin b = b - b³/6 and cos b = 1 - b²/2
+	n := size.Mul(one)
+	u := rem.Mul(rem).Div(n)
+	cosB := one.Sub(u.Div(size).Div(two))
+	sinB := rem.Div(size).Sub(u.Mul(rem).Div(n).Div(size).Div(six))
+
+	sinA, cosA := x.FromInt(t.sin[k.Int()]), x.FromInt(t.cos[k.Int()])
+	sin = sinA.Mul(cosB).Add(cosA.Mul(sinB)).Div(one)
+	cos = cosA.Mul(cosB).Sub(sinA.Mul(sinB)).Div(one)
+	return sin, cos
+}
@@ -0,0 +1,137 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNumericOps(t *testing.T) {
+	testNumericOps[Int256](t, "int256")
+	testNumericOps[Int64](t, "int64")
+	testNumericOps[Float64](t, "float64")
+}
+
+func testNumericOps[T Num[T]](t *testing.T, name string) {
+	var zero T
+	for _, x := range []int64{7, -7, 1e6, -1e6} {
+		for _, y := range []int64{2, -2, 3, -3} {
+			if have := zero.FromInt(x).Div(zero.FromInt(y)).Int(); have != x/y {
+				t.Errorf("%s: %d / %d: have %d, want %d", name, x, y, have, x/y)
+			}
+			if have := zero.FromInt(x).Mod(zero.FromInt(y)).Int(); have != x%y {
+				t.Errorf("%s: %d %% %d: have %d, want %d", name, x, y, have, x%y)
+			}
+			if have := zero.FromInt(x).Cmp(zero.FromInt(y)); have != cmpInt64(x, y) {
+				t.Errorf("%s: cmp(%d, %d): have %d, want %d", name, x, y, have, cmpInt64(x, y))
+			}
+		}
+		if have := zero.FromInt(x).Div(zero).Int(); have != 0 {
+			t.Errorf("%s: %d / 0: have %d, want 0", name, x, have)
+		}
+		if have := zero.FromInt(x).Mod(zero).Int(); have != 0 {
+			t.Errorf("%s: %d %% 0: have %d, want 0", name, x, have)
+		}
+	}
+}
+
+func cmpInt64(x, y int64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func TestNumericSqrtSin(t *testing.T) {
+	for _, x := range []int64{0, 1, 2, 1e6, 123456789, 1e12} {
+		want := toInt64(Sqrt(NewVector(x, 0, 0).X))
+		if have := (Int256{}).FromInt(x).Sqrt(ContractMath).Int(); have != want {
+			t.Errorf("int256: Sqrt(%d): have %d, want %d", x, have, want)
+		}
+		if have := (Int64(0)).FromInt(x).Sqrt(ContractMath).Int(); have != want {
+			t.Errorf("int64: Sqrt(%d): have %d, want %d", x, have, want)
+		}
+	}
+	for _, x := range []int64{0, 500000, 1570796, 3141592, 6283183, -1000000} {
+		want := toInt64(Sin(NewVector(x, 0, 0).X))
+		if have := (Int256{}).FromInt(x).Sin(DefaultScale, ContractMath).Int(); have != want {
+			t.Errorf("int256: Sin(%d): have %d, want %d", x, have, want)
+		}
+		if have := (Float64(0)).FromInt(x).Sin(DefaultScale, ContractMath).Int(); abs(have-want) > 10 {
+			t.Errorf("float64: Sin(%d): have %d, want %d", x, have, want)
+		}
+	}
+}
+
+// TestSceneOf checks that NewSceneOf keeps the scale, math mode and bounding
+// volume hierarchy of the scene, and that it traces the same pixels as the
+// scene on Int256.
+func TestSceneOf(t *testing.T) {
+	s := newLowResScene(t, 16, 12).Rescale(MustScale(9))
+	s.SetMath(FastMath)
+	s.SetBVH(true)
+
+	ns := NewSceneOf[Float64](s)
+	if ns.Scale() != s.Scale() {
+		t.Errorf("scale: have %v, want %v", ns.Scale(), s.Scale())
+	}
+	if ns.Math() != s.Math() {
+		t.Errorf("math: have %v, want %v", ns.Math(), s.Math())
+	}
+	if ns.bvh == nil {
+		t.Error("bounding volume hierarchy dropped")
+	}
+
+	if have, want := NewSceneOf[Int256](s).TraceImage(2), s.TraceImage(2); !bytes.Equal(have, want) {
+		t.Errorf("low resolution image differs:\nhave %v\nwant %v", have, want)
+	}
+}
+
+// TestNumericDivergence logs how far the Int64 and Float64 representations
+// diverge from the contract's Int256 on a low resolution image.
+func TestNumericDivergence(t *testing.T) {
+	s := newLowResScene(t, 32, 24)
+	want := s.TraceImage(2)
+	for name, img := range map[string][]byte{
+		"int64":   NewSceneOf[Int64](s).TraceImage(2),
+		"float64": NewSceneOf[Float64](s).TraceImage(2),
+	} {
+		var pixels, maxDiff int
+		for i := 0; i < len(want); i += 3 {
+			differs := false
+			for c := i; c < i+3; c++ {
+				if d := int(abs(int64(img[c]) - int64(want[c]))); d > 0 {
+					differs = true
+					if d > maxDiff {
+						maxDiff = d
+					}
+				}
+			}
+			if differs {
+				pixels++
+			}
+		}
+		t.Logf("%s: %d of %d pixels differ from int256, by up to %d", name, pixels, len(want)/3, maxDiff)
+	}
+}
+
+// BenchmarkNumeric traces the benchmark pixels with the generic tracer on every
+// number representation.
+func BenchmarkNumeric(b *testing.B) {
+	s := NewBenchmarkScene(0, 0)
+	b.Run("int256", func(b *testing.B) { benchmarkNumeric(b, NewSceneOf[Int256](s)) })
+	b.Run("int64", func(b *testing.B) { benchmarkNumeric(b, NewSceneOf[Int64](s)) })
+	b.Run("float64", func(b *testing.B) { benchmarkNumeric(b, NewSceneOf[Float64](s)) })
+}
+
+func benchmarkNumeric[T Num[T]](b *testing.B, s *SceneOf[T]) {
+	for i := 0; i < b.N; i++ {
+		for _, p := range BenchmarkPixels {
+			s.TracePixel(p.X, p.Y, p.SPP)
+		}
+	}
+}
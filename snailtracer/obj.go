@@ -0,0 +1,164 @@
+package snailtracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/holiman/uint256"
+)
+
+// OBJOptions places a Wavefront OBJ mesh into a scene.
+type OBJOptions struct {
+	// SceneScale is the fixed-point scale of the scene the mesh is placed
+	// into, like SceneBuilder.Scale or Scene.Scale. DefaultScale if nil.
+	SceneScale  *Scale
+	Scale       int64  // Fixed-point units per OBJ unit, one of SceneScale if zero
+	Translation Vector // Offset added to every scaled vertex, none if unset
+	Emission    Vector // Emission of every triangle, none if unset
+	Color       Vector // Color of every triangle, 0.75 gray if unset
+	Material    Material
+}
+
+// LoadOBJ converts the vertices and faces of a Wavefront OBJ file into
+// triangles. Coordinates are scaled and rounded to the nearest fixed-point
+// integer exactly, polygons are split into triangle fans and faces that become
+// degenerate after rounding are dropped. Texture coordinates, vertex normals,
+// groups and materials are ignored. Every triangle has vectors of its own.
+func LoadOBJ(r io.Reader, opts OBJOptions) ([]*Triangle, error) {
+	sc := opts.SceneScale
+	if sc == nil {
+		sc = DefaultScale
+	}
+	scale := big.NewRat(opts.Scale, 1)
+	if opts.Scale == 0 {
+		scale.SetInt(sc.one.ToBig())
+	}
+	translation, err := objOption("translation", opts.Translation, NewVector(0, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	emission, err := objOption("emission", opts.Emission, NewVector(0, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	color, err := objOption("color", opts.Color, Vector{sc.threeQtrs, sc.threeQtrs, sc.threeQtrs})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		vertices  []Vector
+		triangles []*Triangle
+		scanner   = bufio.NewScanner(r)
+		line      int
+	)
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: vertex needs 3 coordinates", line)
+			}
+			var coords [3]*uint256.Int
+			for i := range coords {
+				c, err := objCoordinate(fields[i+1], scale)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				coords[i] = c
+			}
+			vertices = append(vertices, Vector{coords[0], coords[1], coords[2]}.Add(translation))
+
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: face needs at least 3 vertices", line)
+			}
+			face := make([]Vector, len(fields)-1)
+			for i, field := range fields[1:] {
+				index, err := strconv.Atoi(strings.SplitN(field, "/", 2)[0])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid vertex reference %q", line, field)
+				}
+				if index < 0 {
+					// Negative indices are relative to the last vertex read
+					index += len(vertices) + 1
+				}
+				if index < 1 || index > len(vertices) {
+					return nil, fmt.Errorf("line %d: vertex %s out of range", line, field)
+				}
+				face[i] = vertices[index-1]
+			}
+			for i := 2; i < len(face); i++ {
+				tri := &Triangle{
+					a:          copyVector(face[0]),
+					b:          copyVector(face[i-1]),
+					c:          copyVector(face[i]),
+					emission:   copyVector(emission),
+					color:      copyVector(color),
+					reflection: opts.Material,
+				}
+				tri.normal = tri.b.Sub(tri.a).Cross(tri.c.Sub(tri.a)).norm(sc.one)
+				if Cmp(tri.normal.Length(), Big0) == 0 {
+					continue
+				}
+				triangles = append(triangles, tri)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return triangles, nil
+}
+
+// objOption returns the vector v of an OBJOptions field, or def if it is
+// unset. A partially set vector is an error.
+func objOption(name string, v, def Vector) (Vector, error) {
+	if v.X == nil && v.Y == nil && v.Z == nil {
+		return def, nil
+	}
+	if err := checkVector(name, v); err != nil {
+		return Vector{}, err
+	}
+	return v, nil
+}
+
+// objCoordinate parses a decimal OBJ coordinate and scales it to the nearest
+// fixed-point integer, rounding halves away from zero.
+func objCoordinate(s string, scale *big.Rat) (*uint256.Int, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid coordinate %q", s)
+	}
+	r.Mul(r, scale)
+
+	num := new(big.Int).Abs(r.Num())
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if m.Lsh(m, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	x, overflow := uint256.FromBig(q)
+	if overflow || !inSceneRange(x) {
+		return nil, fmt.Errorf("coordinate %s exceeds 2^80 after scaling", s)
+	}
+	if r.Sign() < 0 {
+		x.Neg(x)
+	}
+	return x, nil
+}
+
+// AddMesh appends triangles, such as the ones loaded by LoadOBJ, to the scene.
+func (sb *SceneBuilder) AddMesh(triangles []*Triangle) *SceneBuilder {
+	for _, tri := range triangles {
+		sb.AddTriangle(tri.a, tri.b, tri.c, tri.emission, tri.color, tri.reflection)
+	}
+	return sb
+}
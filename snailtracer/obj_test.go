@@ -0,0 +1,174 @@
+package snailtracer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+const quadOBJ = `# unit square made of one quad
+o square
+v 0 0 0
+v 1.5 0 0
+v 1.5 1 0
+v 0 -1e-6 1.0000005
+vt 0 0
+vn 0 0 1
+f 1/1/1 2/1/1 3/1/1 -1//1
+`
+
+func TestLoadOBJ(t *testing.T) {
+	tris, err := LoadOBJ(strings.NewReader(quadOBJ), OBJOptions{
+		Translation: NewVector(1, 2, 3),
+		Material:    RefractiveMaterial,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tris) != 2 {
+		t.Fatalf("have %d triangles, want 2", len(tris))
+	}
+	want := []Vector{
+		NewVector(1, 2, 3),
+		NewVector(1500001, 2, 3),
+		NewVector(1500001, 1000002, 3),
+		NewVector(1, 1, 1000004),
+	}
+	for i, v := range []Vector{tris[0].a, tris[0].b, tris[0].c, tris[1].c} {
+		if Cmp(v.X, want[i].X) != 0 || Cmp(v.Y, want[i].Y) != 0 || Cmp(v.Z, want[i].Z) != 0 {
+			t.Errorf("vertex %d: have %v, want %v", i, v, want[i])
+		}
+	}
+	if tris[0].normal.Z.Uint64() != 1000000 {
+		t.Errorf("have normal %v, want +Z", tris[0].normal)
+	}
+	if tris[1].reflection != RefractiveMaterial || tris[1].color.X.Uint64() != 750000 {
+		t.Errorf("material not applied to triangle")
+	}
+
+	// Triangles share no vectors, even where they share vertices
+	tris[0].a.X.SetUint64(7)
+	tris[0].color.X.SetUint64(7)
+	if tris[1].a.X.Uint64() != 1 || tris[1].color.X.Uint64() != 750000 {
+		t.Errorf("triangles share vectors")
+	}
+
+	// Scaling down collapses thin faces into degenerate ones that are dropped
+	tris, err = LoadOBJ(strings.NewReader("v 0 0 0\nv 1 0 0\nv 0.6 0.4 0\nv 0 1 0\nf 1 2 3 4\n"), OBJOptions{Scale: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tris) != 1 {
+		t.Fatalf("have %d triangles, want 1", len(tris))
+	}
+}
+
+// TestLoadOBJScale checks that a mesh is loaded at the scale of the scene it
+// is placed into.
+func TestLoadOBJScale(t *testing.T) {
+	sc := MustScale(9)
+	tris, err := LoadOBJ(strings.NewReader(quadOBJ), OBJOptions{SceneScale: sc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := tris[1].c, NewVector(0, -1000, 1000000500); Cmp(have.Y, want.Y) != 0 || Cmp(have.Z, want.Z) != 0 {
+		t.Errorf("vertex: have %v, want %v", have, want)
+	}
+	if have := tris[0].normal.Z.Uint64(); have != 1000000000 {
+		t.Errorf("normal: have %d, want %d", have, 1000000000)
+	}
+	if have := tris[0].color.X.Uint64(); have != 750000000 {
+		t.Errorf("color: have %d, want %d", have, 750000000)
+	}
+
+	s, err := NewSceneBuilder().Scale(sc).
+		Camera(NewVector(0, 0, 5000000000), NewVector(0, 0, -1000000000)).
+		AddMesh(tris).
+		Build(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Scale() != sc {
+		t.Errorf("scene scale: have %v, want %v", s.Scale(), sc)
+	}
+	if have := s.camera.direction[2]; have.Cmp(new(uint256.Int).Neg(sc.one)) != 0 {
+		t.Errorf("camera direction: have %v, want -%v", &have, sc.one)
+	}
+}
+
+func TestLoadOBJErrors(t *testing.T) {
+	for _, input := range []string{
+		"v 1 2\n",
+		"v 1 2 x\n",
+		"v 1e30 0 0\n",
+		"v 0 0 0\nv 1 0 0\nf 1 2\n",
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n",
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 -4\n",
+		"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 a 3\n",
+	} {
+		if _, err := LoadOBJ(strings.NewReader(input), OBJOptions{}); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+	triangle := "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"
+	for i, opts := range []OBJOptions{
+		{Translation: Vector{X: NewBig0()}},
+		{Emission: Vector{Y: NewBig0(), Z: NewBig0()}},
+		{Color: Vector{X: Big1e6, Y: Big1e6}},
+		{Color: Vector{new(uint256.Int).Lsh(Big1, 80), Big1e6, Big1e6}},
+	} {
+		if _, err := LoadOBJ(strings.NewReader(triangle), opts); err == nil {
+			t.Errorf("options %d: expected error", i)
+		}
+	}
+}
+
+func newOBJScene(tb testing.TB) *Scene {
+	file, err := os.Open("testdata/sphere.obj")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	mesh, err := LoadOBJ(file, OBJOptions{
+		Scale:       16500000,
+		Translation: NewVector(73000000, 16500000, 78000000),
+		Color:       NewVector(999000, 999000, 999000),
+		Material:    RefractiveMaterial,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	b := newBenchmarkSceneBuilder(false)
+	s, err := b.AddMesh(mesh).Build(0, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+func TestOBJScene(t *testing.T) {
+	s := newOBJScene(t)
+	if len(s.triangles) != 960 {
+		t.Fatalf("have %d triangles, want 960", len(s.triangles))
+	}
+	for _, tri := range s.triangles {
+		// Outward facing: the normal points away from the mesh center
+		if Cmp(tri.normal.Dot(tri.a.Sub(NewVector(73000000, 16500000, 78000000))), Big0) <= 0 {
+			t.Fatalf("inward facing normal %v", tri.normal)
+		}
+	}
+}
+
+func BenchmarkOBJSnailtracer(b *testing.B) {
+	s := newOBJScene(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		color := NewVector(0, 0, 0)
+		color = color.Add(s.Trace(512, 384, 8))
+		color = color.Add(s.Trace(600, 600, 8))
+		color = color.ScaleDiv(uint256.NewInt(2))
+	}
+}
@@ -0,0 +1,230 @@
+package snailtracer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/holiman/uint256"
+)
+
+// Scale is the fixed-point scale a Scene is traced at: every length, color and
+// angle is an integer multiple of 10^-Digits. The contract, and DefaultScale,
+// traces at 1e6. The constants of the tracer are the contract's, rescaled,
+// except 2π, which is computed to the precision of the scale: the contract's
+// 6283184 is kept only at 1e6, to trace bit-identical pixels.
+type Scale struct {
+	digits int
+
+	one       *uint256.Int // 1
+	sq        *uint256.Int // 1, at twice the digits
+	root      *uint256.Int // the square root of one, if it is an integer
+	epsilon   *uint256.Int // 0.001, the smallest hit distance
+	tenth     *uint256.Int // 0.1
+	quarter   *uint256.Int // 0.25
+	half      *uint256.Int // 0.5
+	threeQtrs *uint256.Int // 0.75
+	fresnel0  *uint256.Int // 0.04, the reflectance of glass at normal incidence
+	fov       *uint256.Int // 0.5135, the field of view per image dimension
+	twoPi     *uint256.Int // 2π
+	nntEnter  *uint256.Int // 2/3, the ratio of refractive indices entering glass
+	nntLeave  *uint256.Int // 1.5, and leaving it
+
+	trig trigTable // the FastTrig tables
+}
+
+// MinScaleDigits and MaxScaleDigits bound the number of decimals of a Scale.
+// Below 3 the smallest hit distance is zero; above 12 the contract's Fresnel
+// term overflows int256 for the benchmark scene.
+const (
+	MinScaleDigits = 3
+	MaxScaleDigits = 12
+)
+
+// DefaultScale is the contract's 1e6 scale.
+var DefaultScale = MustScale(6)
+
+// NewScale returns the scale with the given number of decimals, 1e<digits>.
+func NewScale(digits int) (*Scale, error) {
+	if digits < MinScaleDigits || digits > MaxScaleDigits {
+		return nil, fmt.Errorf("scale 1e%d out of range [1e%d, 1e%d]", digits, MinScaleDigits, MaxScaleDigits)
+	}
+	unit := uint64(math.Pow10(digits))
+	fraction := func(num, den uint64) *uint256.Int {
+		return uint256.NewInt(unit * num / den)
+	}
+	sc := &Scale{
+		digits:    digits,
+		one:       uint256.NewInt(unit),
+		epsilon:   fraction(1, 1000),
+		tenth:     fraction(1, 10),
+		quarter:   fraction(1, 4),
+		half:      fraction(1, 2),
+		threeQtrs: fraction(3, 4),
+		fresnel0:  fraction(4, 100),
+		fov:       fraction(5135, 10000),
+		twoPi:     uint256.NewInt(uint64(2 * math.Pi * float64(unit))),
+		nntEnter:  uint256.NewInt(2 * unit / 3),
+		nntLeave:  fraction(3, 2),
+	}
+	if digits == 6 {
+		sc.twoPi = uint256.NewInt(6283184)
+	}
+	sc.sq = new(uint256.Int).Mul(sc.one, sc.one)
+	if digits%2 == 0 {
+		sc.root = uint256.NewInt(uint64(math.Pow10(digits / 2)))
+	}
+	return sc, nil
+}
+
+// MustScale is like NewScale but panics if digits is out of range.
+func MustScale(digits int) *Scale {
+	sc, err := NewScale(digits)
+	if err != nil {
+		panic(err)
+	}
+	return sc
+}
+
+// Digits returns the number of decimals of the scale.
+func (sc *Scale) Digits() int {
+	return sc.digits
+}
+
+func (sc *Scale) String() string {
+	return fmt.Sprintf("1e%d", sc.digits)
+}
+
+// rescale returns x, at scale from, at the scale sc.
+func (sc *Scale) rescale(x *uint256.Int, from *Scale) *uint256.Int {
+	z := new(uint256.Int).Mul(x, sc.one)
+	return z.SDiv(z, from.one)
+}
+
+func (sc *Scale) rescaleVector(v Vector, from *Scale) Vector {
+	return Vector{sc.rescale(v.X, from), sc.rescale(v.Y, from), sc.rescale(v.Z, from)}
+}
+
+// sqrtScaled returns the square root of x as a fixed-point value, for x at the
+// scale: sqrt(x * one), computed like the contract as sqrt(x) * sqrt(one) when
+// the latter is an integer, with the square root of m.
+func (sc *Scale) sqrtScaled(x *uint256.Int, m MathMode) uint256.Int {
+	if sc.root != nil {
+		y := m.sqrt(x)
+		y.Mul(&y, sc.root)
+		return y
+	}
+	var y uint256.Int
+	y.Mul(x, sc.one)
+	return m.sqrt(&y)
+}
+
+// numScale holds the constants of a Scale, and the tracer's constants that do
+// not depend on it, in the number representation T.
+type numScale[T Num[T]] struct {
+	scale *Scale
+
+	zero, neg1, two T
+	one, sq         T
+	root            T // the square root of one, if hasRoot
+	hasRoot         bool
+	epsilon, tenth  T
+	negEpsilon      T
+	quarter, half   T
+	threeQtrs       T
+	fresnel0, fov   T
+	twoPi           T
+	nntEnter        T
+	nntLeave        T
+	big140, big255  T
+}
+
+func newNumScale[T Num[T]](sc *Scale) *numScale[T] {
+	var zero T
+	n := &numScale[T]{
+		scale:     sc,
+		zero:      zero,
+		neg1:      zero.FromInt(-1),
+		two:       zero.FromInt(2),
+		one:       numOf[T](sc.one),
+		epsilon:   numOf[T](sc.epsilon),
+		tenth:     numOf[T](sc.tenth),
+		quarter:   numOf[T](sc.quarter),
+		half:      numOf[T](sc.half),
+		threeQtrs: numOf[T](sc.threeQtrs),
+		fresnel0:  numOf[T](sc.fresnel0),
+		fov:       numOf[T](sc.fov),
+		twoPi:     numOf[T](sc.twoPi),
+		nntEnter:  numOf[T](sc.nntEnter),
+		nntLeave:  numOf[T](sc.nntLeave),
+		big140:    zero.FromInt(140),
+		big255:    zero.FromInt(255),
+	}
+	n.sq = n.one.Mul(n.one)
+	n.negEpsilon = n.epsilon.Neg()
+	if sc.root != nil {
+		n.root, n.hasRoot = numOf[T](sc.root), true
+	}
+	return n
+}
+
+// sqrtScaled is Scale.sqrtScaled in T.
+func (sc *numScale[T]) sqrtScaled(x T, m MathMode) T {
+	if sc.hasRoot {
+		return x.Sqrt(m).Mul(sc.root)
+	}
+	return x.Mul(sc.one).Sqrt(m)
+}
+
+// Scale returns the fixed-point scale the scene is traced at.
+func (s *SceneOf[T]) Scale() *Scale {
+	return s.scale
+}
+
+// Rescale returns a copy of the scene traced at the scale sc, or s itself if it
+// is already at that scale. Every coordinate, radius and color is converted,
+// truncating the digits a coarser scale cannot hold, and the camera direction
+// and triangle normals are normalized again at the new scale.
+func (s *SceneOf[T]) Rescale(sc *Scale) *SceneOf[T] {
+	if sc.digits == s.scale.digits {
+		return s
+	}
+	from := s.scale
+	r := &SceneOf[T]{
+		id:     s.id,
+		seed:   s.seed,
+		width:  s.width,
+		height: s.height,
+		scale:  sc,
+		math:   s.math,
+	}
+	r.setCamera(
+		sc.rescaleVector(s.camera.origin.Vector(), from),
+		sc.rescaleVector(s.camera.direction.Vector(), from).norm(sc.one),
+	)
+	r.spheres = make([]*Sphere, len(s.spheres))
+	for i, sphere := range s.spheres {
+		r.spheres[i] = &Sphere{
+			radius:     sc.rescale(sphere.radius, from),
+			position:   sc.rescaleVector(sphere.position, from),
+			emission:   sc.rescaleVector(sphere.emission, from),
+			color:      sc.rescaleVector(sphere.color, from),
+			reflection: sphere.reflection,
+		}
+	}
+	r.triangles = make([]*Triangle, len(s.triangles))
+	for i, tri := range s.triangles {
+		r.triangles[i] = &Triangle{
+			a:          sc.rescaleVector(tri.a, from),
+			b:          sc.rescaleVector(tri.b, from),
+			c:          sc.rescaleVector(tri.c, from),
+			emission:   sc.rescaleVector(tri.emission, from),
+			color:      sc.rescaleVector(tri.color, from),
+			reflection: tri.reflection,
+		}
+	}
+	r.prepare()
+	if s.bvh != nil && r.bvh == nil {
+		r.SetBVH(true)
+	}
+	return r
+}
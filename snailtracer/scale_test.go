@@ -0,0 +1,113 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewScale(t *testing.T) {
+	for _, digits := range []int{MinScaleDigits - 1, MaxScaleDigits + 1} {
+		if _, err := NewScale(digits); err == nil {
+			t.Errorf("NewScale(%d) succeeded", digits)
+		}
+	}
+	// The 1e6 constants are the contract's
+	sc := DefaultScale
+	for _, c := range []struct {
+		name       string
+		have, want int64
+	}{
+		{"one", toInt64(sc.one), 1e6},
+		{"epsilon", toInt64(sc.epsilon), 1e3},
+		{"fov", toInt64(sc.fov), 513500},
+		{"twoPi", toInt64(sc.twoPi), 6283184},
+		{"nntEnter", toInt64(sc.nntEnter), 666666},
+		{"nntLeave", toInt64(sc.nntLeave), 1500000},
+	} {
+		if c.have != c.want {
+			t.Errorf("%s: have %d, want %d", c.name, c.have, c.want)
+		}
+	}
+	if have := MustScale(9).twoPi.Uint64(); have != 6283185307 {
+		t.Errorf("1e9 twoPi: have %d, want 6283185307", have)
+	}
+}
+
+// TestRescaleDefault checks that the 1e6 scale traces the contract's pixels.
+func TestRescaleDefault(t *testing.T) {
+	s := NewBenchmarkScene(0, 0)
+	if s.Scale() != DefaultScale || s.Rescale(MustScale(6)) != s {
+		t.Fatal("benchmark scene is not at the default scale")
+	}
+	// Rescaling up by 1e3 and back is exact for the benchmark scene
+	s = s.Rescale(MustScale(9)).Rescale(DefaultScale)
+	for _, p := range BenchmarkPixels {
+		r, g, b := s.TracePixel(p.X, p.Y, p.SPP)
+		wr, wg, wb := NewBenchmarkScene(0, 0).TracePixel(p.X, p.Y, p.SPP)
+		if r != wr || g != wg || b != wb {
+			t.Errorf("pixel (%d, %d): have %d %d %d, want %d %d %d", p.X, p.Y, r, g, b, wr, wg, wb)
+		}
+	}
+}
+
+// TestRescaleBVH checks that rescaled scenes trace the same with and without a
+// bounding volume hierarchy.
+func TestRescaleBVH(t *testing.T) {
+	for _, digits := range []int{4, 9} {
+		s := newLowResScene(t, 16, 12).Rescale(MustScale(digits))
+		want := s.TraceImage(1)
+		s.SetBVH(true)
+		if have := s.TraceImage(1); !bytes.Equal(have, want) {
+			t.Errorf("1e%d: image traced through the BVH differs", digits)
+		}
+	}
+}
+
+// TestScaleQuality logs how far the images traced at a few scales are from the
+// float64 reference, and checks that none overflows into garbage.
+func TestScaleQuality(t *testing.T) {
+	s := newLowResScene(t, 32, 24)
+	want := NewSceneOf[Float64](s).TraceImage(2)
+	for _, digits := range []int{MinScaleDigits, 4, 6, 9, MaxScaleDigits} {
+		img := s.Rescale(MustScale(digits)).TraceImage(2)
+		var pixels, sum, maxDiff int
+		for i := 0; i < len(want); i += 3 {
+			differs := false
+			for c := i; c < i+3; c++ {
+				if d := int(abs(int64(img[c]) - int64(want[c]))); d > 0 {
+					differs = true
+					sum += d
+					if d > maxDiff {
+						maxDiff = d
+					}
+				}
+			}
+			if differs {
+				pixels++
+			}
+		}
+		mean := float64(sum) / float64(len(want))
+		t.Logf("1e%d: %d of %d pixels differ from float64, by %.2f on average and up to %d", digits, pixels, len(want)/3, mean, maxDiff)
+		if mean > 16 {
+			t.Errorf("1e%d: mean difference %.2f from float64", digits, mean)
+		}
+	}
+}
+
+// BenchmarkScale traces the benchmark pixels at the 1e4, 1e6 and 1e9 scales.
+// The backends package benchmarks the Wasm backends at the same scales.
+func BenchmarkScale(b *testing.B) {
+	for _, digits := range []int{4, 6, 9} {
+		sc := MustScale(digits)
+		b.Run(sc.String(), func(b *testing.B) {
+			s := NewBenchmarkScene(0, 0).Rescale(sc)
+			for i := 0; i < b.N; i++ {
+				for _, p := range BenchmarkPixels {
+					s.TracePixel(p.X, p.Y, p.SPP)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/holiman/uint256"
+	"gopkg.in/yaml.v3"
+)
+
+// SceneFormat is the encoding of a scene description file.
+type SceneFormat int
+
+const (
+	JSONSceneFormat SceneFormat = iota
+	YAMLSceneFormat
+)
+
+// SceneFormatFromPath picks the scene format from a file extension.
+func SceneFormatFromPath(path string) (SceneFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSONSceneFormat, nil
+	case ".yaml", ".yml":
+		return YAMLSceneFormat, nil
+	}
+	return 0, fmt.Errorf("unknown scene format for %q", path)
+}
+
+// sceneFile is the on-disk description of a scene. All coordinates, colors and
+// radii are fixed-point integers scaled by 1e6, exactly as the tracer stores
+// them. The camera direction is used verbatim and must already be normalized;
+// the field of view increments and the triangle normals are derived on load.
+type sceneFile struct {
+	Width     int            `json:"width" yaml:"width"`
+	Height    int            `json:"height" yaml:"height"`
+	Camera    cameraFile     `json:"camera" yaml:"camera"`
+	Spheres   []sphereFile   `json:"spheres" yaml:"spheres"`
+	Triangles []triangleFile `json:"triangles" yaml:"triangles"`
+}
+
+type cameraFile struct {
+	Origin    vectorFile `json:"origin" yaml:"origin"`
+	Direction vectorFile `json:"direction" yaml:"direction"`
+}
+
+type sphereFile struct {
+	Radius   fixed      `json:"radius" yaml:"radius"`
+	Position vectorFile `json:"position" yaml:"position"`
+	Emission vectorFile `json:"emission" yaml:"emission"`
+	Color    vectorFile `json:"color" yaml:"color"`
+	Material Material   `json:"material" yaml:"material"`
+}
+
+type triangleFile struct {
+	A        vectorFile `json:"a" yaml:"a"`
+	B        vectorFile `json:"b" yaml:"b"`
+	C        vectorFile `json:"c" yaml:"c"`
+	Emission vectorFile `json:"emission" yaml:"emission"`
+	Color    vectorFile `json:"color" yaml:"color"`
+	Material Material   `json:"material" yaml:"material"`
+}
+
+// LoadScene decodes a scene description and prepares it for rendering the same
+// way NewBenchmarkScene does.
+func LoadScene(r io.Reader, format SceneFormat, id, seed int) (*Scene, error) {
+	var f sceneFile
+	switch format {
+	case JSONSceneFormat:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("failed to decode scene: %w", err)
+		}
+	case YAMLSceneFormat:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("failed to decode scene: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown scene format %d", format)
+	}
+	if f.Width <= 0 || f.Height <= 0 {
+		return nil, fmt.Errorf("invalid scene resolution %dx%d", f.Width, f.Height)
+	}
+
+	s := newScene(f.Width, f.Height, seed)
+	s.id = id
+	s.setCamera(f.Camera.Origin.vector(), f.Camera.Direction.vector())
+
+	s.spheres = make([]*Sphere, len(f.Spheres))
+	for i, sf := range f.Spheres {
+		s.spheres[i] = &Sphere{
+			radius:     sf.Radius.int(),
+			position:   sf.Position.vector(),
+			emission:   sf.Emission.vector(),
+			color:      sf.Color.vector(),
+			reflection: sf.Material,
+		}
+	}
+	s.triangles = make([]*Triangle, len(f.Triangles))
+	for i, tf := range f.Triangles {
+		s.triangles[i] = &Triangle{
+			a:          tf.A.vector(),
+			b:          tf.B.vector(),
+			c:          tf.C.vector(),
+			emission:   tf.Emission.vector(),
+			color:      tf.Color.vector(),
+			reflection: tf.Material,
+		}
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	s.prepare()
+	return s, nil
+}
+
+// SaveScene encodes the description of a scene, at the 1e6 scale. Derived
+// values (field of view increments and triangle normals) are not stored.
+func SaveScene(w io.Writer, format SceneFormat, s *Scene) error {
+	s = s.Rescale(DefaultScale)
+	f := sceneFile{
+		Width:  s.width,
+		Height: s.height,
+		Camera: cameraFile{
+			Origin:    newVectorFile(s.camera.origin.Vector()),
+			Direction: newVectorFile(s.camera.direction.Vector()),
+		},
+		Spheres:   make([]sphereFile, len(s.spheres)),
+		Triangles: make([]triangleFile, len(s.triangles)),
+	}
+	for i, sphere := range s.spheres {
+		f.Spheres[i] = sphereFile{
+			Radius:   fixed(*sphere.radius),
+			Position: newVectorFile(sphere.position),
+			Emission: newVectorFile(sphere.emission),
+			Color:    newVectorFile(sphere.color),
+			Material: sphere.reflection,
+		}
+	}
+	for i, tri := range s.triangles {
+		f.Triangles[i] = triangleFile{
+			A:        newVectorFile(tri.a),
+			B:        newVectorFile(tri.b),
+			C:        newVectorFile(tri.c),
+			Emission: newVectorFile(tri.emission),
+			Color:    newVectorFile(tri.color),
+			Material: tri.reflection,
+		}
+	}
+
+	switch format {
+	case JSONSceneFormat:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&f)
+	case YAMLSceneFormat:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&f); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown scene format %d", format)
+}
+
+// LoadSceneFile reads a scene description from disk, picking the format from
+// the file extension.
+func LoadSceneFile(path string, id, seed int) (*Scene, error) {
+	format, err := SceneFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadScene(file, format, id, seed)
+}
+
+// SaveSceneFile writes a scene description to disk, picking the format from the
+// file extension.
+func SaveSceneFile(path string, s *Scene) error {
+	format, err := SceneFormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := SaveScene(&buf, format, s); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func (m Material) MarshalText() ([]byte, error) {
+	switch m {
+	case DiffuseMaterial, SpecularMaterial, RefractiveMaterial:
+		return []byte(m.String()), nil
+	}
+	return nil, fmt.Errorf("unknown material %d", int(m))
+}
+
+func (m *Material) UnmarshalText(text []byte) error {
+	for _, candidate := range []Material{DiffuseMaterial, SpecularMaterial, RefractiveMaterial} {
+		if string(text) == candidate.String() {
+			*m = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown material %q", text)
+}
+
+// fixed is a signed 256 bit fixed-point scalar encoded as a decimal integer.
+type fixed uint256.Int
+
+func (f fixed) int() *uint256.Int {
+	x := uint256.Int(f)
+	return &x
+}
+
+func (f fixed) String() string {
+	x := uint256.Int(f)
+	if x.Sign() < 0 {
+		return new(big.Int).Neg(new(uint256.Int).Neg(&x).ToBig()).String()
+	}
+	return x.ToBig().String()
+}
+
+func (f *fixed) parse(s string) error {
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid fixed-point value %q", s)
+	}
+	if b.Cmp(minInt256) < 0 || b.Cmp(maxInt256) > 0 {
+		return fmt.Errorf("fixed-point value %s overflows int256", s)
+	}
+	x, _ := uint256.FromBig(new(big.Int).Abs(b))
+	if b.Sign() < 0 {
+		x.Neg(x)
+	}
+	*f = fixed(*x)
+	return nil
+}
+
+func (f fixed) MarshalJSON() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *fixed) UnmarshalJSON(data []byte) error {
+	return f.parse(string(data))
+}
+
+func (f fixed) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: f.String()}, nil
+}
+
+func (f *fixed) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: fixed-point value must be a scalar", value.Line)
+	}
+	return f.parse(value.Value)
+}
+
+// vectorFile is a Vector encoded as an [x, y, z] list.
+type vectorFile [3]fixed
+
+func newVectorFile(v Vector) vectorFile {
+	return vectorFile{fixed(*v.X), fixed(*v.Y), fixed(*v.Z)}
+}
+
+func (v vectorFile) vector() Vector {
+	return Vector{v[0].int(), v[1].int(), v[2].int()}
+}
+
+func (v vectorFile) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, f := range v {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: f.String()})
+	}
+	return node, nil
+}
+
+func (v *vectorFile) set(xs []fixed) error {
+	if len(xs) != len(v) {
+		return fmt.Errorf("vector must have %d components, got %d", len(v), len(xs))
+	}
+	copy(v[:], xs)
+	return nil
+}
+
+func (v *vectorFile) UnmarshalJSON(data []byte) error {
+	var xs []fixed
+	if err := json.Unmarshal(data, &xs); err != nil {
+		return err
+	}
+	return v.set(xs)
+}
+
+func (v *vectorFile) UnmarshalYAML(value *yaml.Node) error {
+	var xs []fixed
+	if err := value.Decode(&xs); err != nil {
+		return err
+	}
+	if err := v.set(xs); err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	return nil
+}
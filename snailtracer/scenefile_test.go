@@ -0,0 +1,76 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const benchmarkSceneFile = "../scenes/benchmark.yaml"
+
+func TestBenchmarkSceneFile(t *testing.T) {
+	want, err := os.ReadFile(benchmarkSceneFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := SaveScene(&buf, YAMLSceneFormat, NewBenchmarkScene(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("benchmark scene does not match %s", benchmarkSceneFile)
+	}
+
+	s, err := LoadSceneFile(benchmarkSceneFile, 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, NewBenchmarkScene(3, 7)) {
+		t.Fatal("loaded scene differs from the benchmark scene")
+	}
+}
+
+func TestSceneRoundTrip(t *testing.T) {
+	for _, format := range []SceneFormat{JSONSceneFormat, YAMLSceneFormat} {
+		var first, second bytes.Buffer
+		if err := SaveScene(&first, format, NewBenchmarkScene(0, 0)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := LoadScene(bytes.NewReader(first.Bytes()), format, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s, NewBenchmarkScene(0, 0)) {
+			t.Fatalf("format %d: loaded scene differs from the benchmark scene", format)
+		}
+		if err := SaveScene(&second, format, s); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("format %d: scene did not round-trip byte-for-byte", format)
+		}
+	}
+}
+
+func TestLoadSceneErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"resolution", `{"width": 0, "height": 768}`},
+		{"vector", `{"width": 1, "height": 1, "camera": {"origin": [1, 2]}}`},
+		{"fraction", `{"width": 1, "height": 1, "spheres": [{"radius": 1.5}]}`},
+		{"overflow", `{"width": 1, "height": 1, "spheres": [{"radius": 57896044618658097711785492504343953926634992332820282019728792003956564819968}]}`},
+		{"material", `{"width": 1, "height": 1, "spheres": [{"material": "glass"}]}`},
+		{"field", `{"width": 1, "height": 1, "fov": 1}`},
+	}
+	for _, test := range tests {
+		if _, err := LoadScene(strings.NewReader(test.input), JSONSceneFormat, 0, 0); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package snailtracer
 
 import (
+	"fmt"
+
 	"github.com/holiman/uint256"
 )
 
 type Ray struct {
-	origin, direction Vector
+	origin, direction Vec
 	depth             int
 	refract           bool
 }
@@ -19,30 +21,9 @@ type Sphere struct {
 }
 
 func (s *Sphere) Intersect(r *Ray) *uint256.Int {
-	op := s.position.Sub(r.origin)
-	b := new(uint256.Int).SDiv(op.Dot(r.direction), Big1e6)
-
-	bSq := new(uint256.Int).Mul(b, b)
-	rSq := new(uint256.Int).Mul(s.radius, s.radius)
-	sSq := new(uint256.Int).Add(rSq, bSq)
-	det := new(uint256.Int).Sub(sSq, op.Dot(op))
-
-	if Cmp(det, Big0) <= 0 {
-		return NewBig0()
-	}
-
-	detSqrt := Sqrt(det)
-
-	bMinusDetSqrt := new(uint256.Int).Sub(b, detSqrt)
-	bPlusDetSqrt := new(uint256.Int).Add(b, detSqrt)
-
-	if Cmp(bMinusDetSqrt, Big1e3) > 0 {
-		return bMinusDetSqrt
-	}
-	if Cmp(bPlusDetSqrt, Big1e3) > 0 {
-		return bPlusDetSqrt
-	}
-	return NewBig0()
+	ray, sphere := numRayOf[Int256](r), numSphereOf[Int256](s)
+	dist := sphere.intersect(&ray, newNumScale[Int256](DefaultScale), ContractMath)
+	return &dist.v
 }
 
 type Triangle struct {
@@ -54,35 +35,9 @@ type Triangle struct {
 }
 
 func (t *Triangle) Intersect(r *Ray) *uint256.Int {
-	e1 := t.b.Sub(t.a)
-	e2 := t.c.Sub(t.a)
-	p := r.direction.Cross(e2)
-
-	det := new(uint256.Int).SDiv(e1.Dot(p), Big1e6)
-	if Cmp(det, new(uint256.Int).Neg(Big1e3)) > 0 && Cmp(det, Big1e3) < 0 {
-		return NewBig0()
-	}
-
-	d := r.origin.Sub(t.a)
-	u := new(uint256.Int).SDiv(d.Dot(p), det)
-
-	if Cmp(u, Big0) < 0 || Cmp(u, Big1e6) > 0 {
-		return NewBig0()
-	}
-
-	q := d.Cross(e1)
-	v := new(uint256.Int).SDiv(r.direction.Dot(q), det)
-
-	if Cmp(v, Big0) < 0 || Cmp(new(uint256.Int).Add(u, v), Big1e6) > 0 {
-		return NewBig0()
-	}
-
-	dist := new(uint256.Int).SDiv(e2.Dot(q), det)
-
-	if Cmp(dist, Big1e3) < 0 {
-		return NewBig0()
-	}
-	return dist
+	ray, tri := numRayOf[Int256](r), numTriangleOf[Int256](t)
+	dist := tri.intersect(&ray, newNumScale[Int256](DefaultScale))
+	return &dist.v
 }
 
 type Material int
@@ -93,6 +48,18 @@ const (
 	RefractiveMaterial
 )
 
+func (m Material) String() string {
+	switch m {
+	case DiffuseMaterial:
+		return "diffuse"
+	case SpecularMaterial:
+		return "specular"
+	case RefractiveMaterial:
+		return "refractive"
+	}
+	return fmt.Sprintf("Material(%d)", int(m))
+}
+
 type Primitive int
 
 const (
@@ -100,267 +67,432 @@ const (
 	TrianglePrimitive
 )
 
-type Scene struct {
-	id             int
-	seed           uint32
-	width, height  int
-	camera         *Ray
-	deltaX, deltaY Vector
-	spheres        []*Sphere
-	triangles      []*Triangle
+// numRay, numSphere and numTriangle are Ray, Sphere and Triangle in the number
+// representation T.
+type numRay[T Num[T]] struct {
+	origin, direction numVector[T]
+	depth             int
+	refract           bool
+}
+
+func numRayOf[T Num[T]](r *Ray) numRay[T] {
+	return numRay[T]{
+		origin:    numVectorOf[T](r.origin.Vector()),
+		direction: numVectorOf[T](r.direction.Vector()),
+		depth:     r.depth,
+		refract:   r.refract,
+	}
+}
+
+type numSphere[T Num[T]] struct {
+	radius     T
+	position   numVector[T]
+	emission   numVector[T]
+	color      numVector[T]
+	reflection Material
+}
+
+func numSphereOf[T Num[T]](s *Sphere) numSphere[T] {
+	return numSphere[T]{
+		radius:     numOf[T](s.radius),
+		position:   numVectorOf[T](s.position),
+		emission:   numVectorOf[T](s.emission),
+		color:      numVectorOf[T](s.color),
+		reflection: s.reflection,
+	}
 }
 
+// intersect is Sphere.Intersect at the scale sc with the square root of m.
+func (obj *numSphere[T]) intersect(r *numRay[T], sc *numScale[T], m MathMode) T {
+	op := obj.position.sub(r.origin)
+	b := op.dot(r.direction).Div(sc.one)
+
+	det := obj.radius.Mul(obj.radius).Add(b.Mul(b)).Sub(op.dot(op))
+	if det.Cmp(sc.zero) <= 0 {
+		return sc.zero
+	}
+
+	detSqrt := det.Sqrt(m)
+	if d := b.Sub(detSqrt); d.Cmp(sc.epsilon) > 0 {
+		return d
+	}
+	if d := b.Add(detSqrt); d.Cmp(sc.epsilon) > 0 {
+		return d
+	}
+	return sc.zero
+}
+
+type numTriangle[T Num[T]] struct {
+	a, b, c    numVector[T]
+	e1, e2     numVector[T] // the edges b-a and c-a
+	normal     numVector[T]
+	emission   numVector[T]
+	color      numVector[T]
+	reflection Material
+}
+
+func numTriangleOf[T Num[T]](t *Triangle) numTriangle[T] {
+	tri := numTriangle[T]{
+		a:          numVectorOf[T](t.a),
+		b:          numVectorOf[T](t.b),
+		c:          numVectorOf[T](t.c),
+		normal:     numVectorOf[T](t.normal),
+		emission:   numVectorOf[T](t.emission),
+		color:      numVectorOf[T](t.color),
+		reflection: t.reflection,
+	}
+	tri.e1 = tri.b.sub(tri.a)
+	tri.e2 = tri.c.sub(tri.a)
+	return tri
+}
+
+// intersect is Triangle.Intersect at the scale sc.
+func (obj *numTriangle[T]) intersect(r *numRay[T], sc *numScale[T]) T {
+	p := r.direction.cross(obj.e2)
+
+	det := obj.e1.dot(p).Div(sc.one)
+	if det.Cmp(sc.negEpsilon) > 0 && det.Cmp(sc.epsilon) < 0 {
+		return sc.zero
+	}
+
+	d := r.origin.sub(obj.a)
+	u := d.dot(p).Div(det)
+	if u.Cmp(sc.zero) < 0 || u.Cmp(sc.one) > 0 {
+		return sc.zero
+	}
+
+	q := d.cross(obj.e1)
+	v := r.direction.dot(q).Div(det)
+	if v.Cmp(sc.zero) < 0 || u.Add(v).Cmp(sc.one) > 0 {
+		return sc.zero
+	}
+
+	dist := obj.e2.dot(q).Div(det)
+	if dist.Cmp(sc.epsilon) < 0 {
+		return sc.zero
+	}
+	return dist
+}
+
+// SceneOf is a scene traced on the number representation T, to compare
+// representations. Scene, on the contract's Int256, traces the same pixels as
+// the contract; other representations diverge where they round or overflow
+// differently. The scene is described by int256 Vectors at its scale and
+// converted to T for tracing.
+type SceneOf[T Num[T]] struct {
+	id            int
+	seed          uint32
+	width, height int
+	camera        *Ray
+	spheres       []*Sphere
+	triangles     []*Triangle
+	bvh           *bvhNode[T]
+	scale         *Scale
+	math          MathMode
+
+	// The scene in T, derived from the description above by convert
+	sc             *numScale[T]
+	view           numRay[T]
+	deltaX, deltaY numVector[T]
+	numSpheres     []numSphere[T]
+	numTriangles   []numTriangle[T]
+}
+
+// Scene is the scene traced on the contract's int256 numbers.
+type Scene = SceneOf[Int256]
+
 func newScene(w, h, seed int) *Scene {
-	s := &Scene{}
+	s := &Scene{scale: DefaultScale}
 	s.width = w
 	s.height = h
 	s.seed = uint32(seed)
 	return s
 }
 
-func (s *Scene) rand() *uint256.Int {
+// NewSceneOf converts s to the number representation T, keeping its scale,
+// math mode and bounding volume hierarchy.
+func NewSceneOf[T Num[T]](s *Scene) *SceneOf[T] {
+	n := &SceneOf[T]{
+		id:        s.id,
+		seed:      s.seed,
+		width:     s.width,
+		height:    s.height,
+		camera:    s.camera,
+		spheres:   s.spheres,
+		triangles: s.triangles,
+		scale:     s.scale,
+		math:      s.math,
+	}
+	n.convert()
+	if s.bvh != nil {
+		n.SetBVH(true)
+	}
+	return n
+}
+
+// setCamera places the camera.
+func (s *SceneOf[T]) setCamera(origin, direction Vector) {
+	s.camera = new(Ray)
+	s.camera.origin.SetVector(origin)
+	s.camera.direction.SetVector(direction)
+}
+
+// prepare calculates all the triangle surface normals, converts the scene to T
+// and builds the bounding volume hierarchy for large scenes.
+func (s *SceneOf[T]) prepare() {
+	for i := range s.triangles {
+		tri := s.triangles[i]
+		tri.normal = tri.b.Sub(tri.a).Cross(tri.c.Sub(tri.a)).norm(s.scale.one)
+	}
+	s.convert()
+	if len(s.spheres)+len(s.triangles) > BVHThreshold {
+		s.SetBVH(true)
+	}
+}
+
+// convert derives the scene in T from its description, along with the
+// horizontal and vertical field of view increments per image pixel.
+func (s *SceneOf[T]) convert() {
+	s.sc = newNumScale[T](s.scale)
+	s.view = numRayOf[T](s.camera)
+
+	var dx, width, height uint256.Int
+	width.SetUint64(uint64(s.width))
+	height.SetUint64(uint64(s.height))
+	dx.Mul(&width, s.scale.fov).Div(&dx, &height)
+	s.deltaX = numVector[T]{numOf[T](&dx), s.sc.zero, s.sc.zero}
+	s.deltaY = s.deltaX.cross(s.view.direction).norm(s.sc.one, s.math).
+		scaleMul(s.sc.fov).
+		scaleDiv(s.sc.one)
+
+	s.numSpheres = make([]numSphere[T], len(s.spheres))
+	for i, sphere := range s.spheres {
+		s.numSpheres[i] = numSphereOf[T](sphere)
+	}
+	s.numTriangles = make([]numTriangle[T], len(s.triangles))
+	for i, tri := range s.triangles {
+		s.numTriangles[i] = numTriangleOf[T](tri)
+	}
+}
+
+// randMod advances the random generator and returns its value modulo m.
+func (s *SceneOf[T]) randMod(m int64) T {
 	s.seed = s.seed*1103515245 + 12345
-	return uint256.NewInt(uint64(s.seed))
+	return s.sc.zero.FromInt(int64(s.seed) % m)
+}
+
+// randScaled is randMod for m at the 1e6 scale, converting the value to the
+// scale of the scene so that every scale samples the same paths.
+func (s *SceneOf[T]) randScaled(m int64) T {
+	r := s.randMod(m)
+	if s.scale.digits != DefaultScale.digits {
+		r = r.Mul(s.sc.one).Div(s.sc.zero.FromInt(1e6))
+	}
+	return r
 }
 
-func (s *Scene) trace(x, y, spp int) Vector {
+func (s *SceneOf[T]) trace(x, y, spp int) numVector[T] {
 	s.seed = uint32(s.id*s.width*s.height + y*s.width + x)
-	color := NewVector(0, 0, 0)
+	sc := s.sc
 
+	var (
+		color         numVector[T]
+		width, height = sc.zero.FromInt(int64(s.width)), sc.zero.FromInt(int64(s.height))
+		n             = sc.zero.FromInt(int64(spp))
+	)
 	for k := 0; k < spp; k++ {
-		rdX := s.deltaX.ScaleMul(
-			new(uint256.Int).Sub(
-				new(uint256.Int).SDiv(
-					new(uint256.Int).Add(
-						new(uint256.Int).Mul(Big1e6, uint256.NewInt(uint64(x))),
-						new(uint256.Int).SMod(s.rand(), uint256.NewInt(500000)),
-					),
-					uint256.NewInt(uint64(s.width)),
-				),
-				uint256.NewInt(500000),
-			),
-		)
-		rdY := s.deltaY.ScaleMul(
-			new(uint256.Int).Sub(
-				new(uint256.Int).SDiv(
-					new(uint256.Int).Add(
-						new(uint256.Int).Mul(Big1e6, uint256.NewInt(uint64(y))),
-						new(uint256.Int).SMod(s.rand(), uint256.NewInt(500000)),
-					),
-					uint256.NewInt(uint64(s.height)),
-				),
-				uint256.NewInt(500000),
-			),
-		)
-		pixel := rdX.Add(rdY).ScaleDiv(Big1e6).Add(s.camera.direction)
-		ray := &Ray{
-			origin:    s.camera.origin.Add(pixel.ScaleMul(uint256.NewInt(140))),
-			direction: pixel.Norm(),
+		t := sc.one.Mul(sc.zero.FromInt(int64(x))).Add(s.randScaled(500000)).Div(width).Sub(sc.half)
+		rdX := s.deltaX.scaleMul(t)
+
+		t = sc.one.Mul(sc.zero.FromInt(int64(y))).Add(s.randScaled(500000)).Div(height).Sub(sc.half)
+		rdY := s.deltaY.scaleMul(t)
+
+		pixel := rdX.add(rdY).scaleDiv(sc.one).add(s.view.direction)
+		ray := numRay[T]{
+			origin:    s.view.origin.add(pixel.scaleMul(sc.big140)),
+			direction: pixel.norm(sc.one, s.math),
 		}
-		rad := s.radiance(ray)
-		color = color.Add(rad.ScaleDiv(uint256.NewInt(uint64(spp))))
+
+		color = color.add(s.radiance(&ray).scaleDiv(n))
 	}
 
-	return color.Clamp().ScaleMul(uint256.NewInt(255)).ScaleDiv(Big1e6)
+	return color.clamp(sc.one).scaleMul(sc.big255).scaleDiv(sc.one)
 }
 
-func (s *Scene) radiance(ray *Ray) Vector {
+func (s *SceneOf[T]) radiance(ray *numRay[T]) numVector[T] {
 	if ray.depth > 10 {
-		return NewVector(0, 0, 0)
+		return numVector[T]{}
 	}
 
 	dist, p, id := s.traceRay(ray)
-	if Cmp(dist, Big0) == 0 {
-		return NewVector(0, 0, 0)
+	if dist.Cmp(s.sc.zero) == 0 {
+		return numVector[T]{}
 	}
 
-	var color, emission Vector
-	var sphere *Sphere
-	var triangle *Triangle
-
+	var color, emission numVector[T]
 	if p == SpherePrimitive {
-		sphere = s.spheres[id]
-		color = sphere.color
-		emission = sphere.emission
+		color = s.numSpheres[id].color
+		emission = s.numSpheres[id].emission
 	} else {
-		triangle = s.triangles[id]
-		color = triangle.color
-		emission = triangle.emission
+		color = s.numTriangles[id].color
+		emission = s.numTriangles[id].emission
 	}
 
-	ref := Big1
-	if Cmp(color.X, ref) > 0 {
-		ref = color.X
-	}
-	if Cmp(color.Y, ref) > 0 {
-		ref = color.Y
-	}
-	if Cmp(color.Z, ref) > 0 {
-		ref = color.Z
+	ref := s.sc.zero.FromInt(1)
+	for i := range color {
+		if color[i].Cmp(ref) > 0 {
+			ref = color[i]
+		}
 	}
 
 	ray.depth++
 	if ray.depth > 5 {
-		if Cmp(new(uint256.Int).SMod(s.rand(), Big1e6), ref) < 0 {
-			color = color.ScaleMul(Big1e6).ScaleDiv(ref)
+		if s.randScaled(1e6).Cmp(ref) < 0 {
+			color = color.scaleMul(s.sc.one).scaleDiv(ref)
 		} else {
 			return emission
 		}
 	}
 
-	var result Vector
+	var result numVector[T]
 	if p == SpherePrimitive {
-		result = s.radianceSphere(ray, sphere, dist)
+		result = s.radianceSphere(ray, &s.numSpheres[id], dist)
 	} else {
-		result = s.radianceTriangle(ray, triangle, dist)
+		result = s.radianceTriangle(ray, &s.numTriangles[id], dist)
 	}
-	return emission.Add(color.Mul(result).ScaleDiv(Big1e6))
+	return emission.add(color.mul(result).scaleDiv(s.sc.one))
 }
 
-func (s *Scene) radianceSphere(ray *Ray, obj *Sphere, dist *uint256.Int) Vector {
-	intersect := ray.origin.Add(ray.direction.ScaleMul(dist).ScaleDiv(Big1e6))
-	normal := intersect.Sub(obj.position).Norm()
+func (s *SceneOf[T]) radianceSphere(ray *numRay[T], obj *numSphere[T], dist T) numVector[T] {
+	intersect := ray.origin.add(ray.direction.scaleMul(dist).scaleDiv(s.sc.one))
+	normal := intersect.sub(obj.position).norm(s.sc.one, s.math)
 
 	if obj.reflection == DiffuseMaterial {
-		if Cmp(normal.Dot(ray.direction), Big0) >= 0 {
-			normal = normal.ScaleMul(BigNeg1)
+		if normal.dot(ray.direction).Cmp(s.sc.zero) >= 0 {
+			normal = normal.scaleMul(s.sc.neg1)
 		}
 		return s.diffuse(ray, intersect, normal)
 	}
 	return s.specular(ray, intersect, normal)
 }
 
-func (s *Scene) radianceTriangle(ray *Ray, obj *Triangle, dist *uint256.Int) Vector {
-	intersect := ray.origin.Add(ray.direction.ScaleMul(dist).ScaleDiv(Big1e6))
+func (s *SceneOf[T]) radianceTriangle(ray *numRay[T], obj *numTriangle[T], dist T) numVector[T] {
+	sc := s.sc
+	intersect := ray.origin.add(ray.direction.scaleMul(dist).scaleDiv(sc.one))
 
-	nnt := uint256.NewInt(666666)
+	nnt := sc.nntEnter
 	if ray.refract {
-		nnt = uint256.NewInt(1500000)
-	}
-	ddn := new(uint256.Int).SDiv(obj.normal.Dot(ray.direction), Big1e6)
-	if Cmp(ddn, Big0) >= 0 {
-		ddn = new(uint256.Int).Neg(ddn)
-	}
-	cos2t := new(uint256.Int).Sub(
-		Big1e12,
-		new(uint256.Int).SDiv(
-			new(uint256.Int).Mul(
-				new(uint256.Int).Mul(nnt, nnt),
-				new(uint256.Int).Sub(
-					Big1e12,
-					new(uint256.Int).Mul(ddn, ddn),
-				),
-			),
-			Big1e12,
-		),
-	)
-	if Cmp(cos2t, Big0) < 0 {
+		nnt = sc.nntLeave
+	}
+	ddn := obj.normal.dot(ray.direction).Div(sc.one)
+	if ddn.Cmp(sc.zero) >= 0 {
+		ddn = ddn.Neg()
+	}
+	cos2t := sc.sq.Sub(nnt.Mul(nnt).Mul(sc.sq.Sub(ddn.Mul(ddn))).Div(sc.sq))
+	if cos2t.Cmp(sc.zero) < 0 {
 		return s.specular(ray, intersect, obj.normal)
 	}
 	return s.refractive(ray, intersect, obj.normal, nnt, ddn, cos2t)
 }
 
-func (s *Scene) diffuse(ray *Ray, intersect, normal Vector) Vector {
-	r1 := uint256.NewInt(6283184)
-	r1.Mul(r1, new(uint256.Int).SMod(s.rand(), Big1e6))
-	r1.SDiv(r1, Big1e6)
+func (s *SceneOf[T]) diffuse(ray *numRay[T], intersect, normal numVector[T]) numVector[T] {
+	sc := s.sc
+	r1 := sc.twoPi.Mul(s.randScaled(1e6)).Div(sc.one)
 
-	r2 := new(uint256.Int).SMod(s.rand(), Big1e6)
-	r2s := new(uint256.Int).Mul(Sqrt(r2), Big1e3)
+	r2 := s.randScaled(1e6)
+	r2s := sc.sqrtScaled(r2, s.math)
 
-	var u Vector
-	if Cmp(Abs(normal.X), Big1e5) > 0 {
-		u = NewVector(0, 1000000, 0)
+	var u numVector[T]
+	if x := normal[0]; x.Cmp(sc.tenth) > 0 || x.Neg().Cmp(sc.tenth) > 0 {
+		u[1] = sc.one
 	} else {
-		u = NewVector(1000000, 0, 0)
+		u[0] = sc.one
 	}
-	u = u.Cross(normal).Norm()
+	u = u.cross(normal).norm(sc.one, s.math)
 
-	v := normal.Cross(u).Norm()
+	v := normal.cross(u).norm(sc.one, s.math)
 
-	u1 := u.ScaleMul(new(uint256.Int).SDiv(new(uint256.Int).Mul(Cos(r1), r2s), Big1e6))
-	v1 := v.ScaleMul(new(uint256.Int).SDiv(new(uint256.Int).Mul(Sin(r1), r2s), Big1e6))
-	n1 := normal.ScaleMul(new(uint256.Int).Mul(Sqrt(new(uint256.Int).Sub(Big1e6, r2)), Big1e3))
-	u = u1.Add(v1).Add(n1).Norm()
+	u1 := u.scaleMul(r1.Cos(sc.scale, s.math).Mul(r2s).Div(sc.one))
+	v1 := v.scaleMul(r1.Sin(sc.scale, s.math).Mul(r2s).Div(sc.one))
+	n1 := normal.scaleMul(sc.sqrtScaled(sc.one.Sub(r2), s.math))
+	u = u1.add(v1).add(n1).norm(sc.one, s.math)
 
-	return s.radiance(&Ray{intersect, u, ray.depth, ray.refract})
+	return s.radiance(&numRay[T]{intersect, u, ray.depth, ray.refract})
 }
 
-func (s *Scene) specular(ray *Ray, intersect, normal Vector) Vector {
-	d2 := new(uint256.Int).Mul(Big2, normal.Dot(ray.direction))
-	reflection := ray.direction.Sub(normal.ScaleMul(new(uint256.Int).SDiv(d2, Big1e6))).Norm()
-	return s.radiance(&Ray{intersect, reflection, ray.depth, ray.refract})
+func (s *SceneOf[T]) specular(ray *numRay[T], intersect, normal numVector[T]) numVector[T] {
+	d2 := s.sc.two.Mul(normal.dot(ray.direction))
+	reflection := ray.direction.sub(normal.scaleMul(d2.Div(s.sc.one))).norm(s.sc.one, s.math)
+	return s.radiance(&numRay[T]{intersect, reflection, ray.depth, ray.refract})
 }
 
-func (s *Scene) refractive(ray *Ray, intersect, normal Vector, nnt, ddn, cos2t *uint256.Int) Vector {
-	sign := BigNeg1
+func (s *SceneOf[T]) refractive(ray *numRay[T], intersect, normal numVector[T], nnt, ddn, cos2t T) numVector[T] {
+	sc := s.sc
+	sign := sc.neg1
 	if ray.refract {
-		sign = Big1
+		sign = sc.zero.FromInt(1)
 	}
 
-	temp := new(uint256.Int).Mul(ddn, nnt)
-	temp.SDiv(temp, Big1e6)
-	temp.Add(temp, Sqrt(cos2t))
-	temp.Mul(temp, sign)
+	temp := ddn.Mul(nnt).Div(sc.one).Add(cos2t.Sqrt(s.math)).Mul(sign)
 
-	refraction := ray.direction.ScaleMul(nnt).
-		Sub(normal.ScaleMul(temp)).
-		ScaleDiv(Big1e6).
-		Norm()
+	refraction := ray.direction.scaleMul(nnt).
+		sub(normal.scaleMul(temp)).
+		scaleDiv(sc.one).
+		norm(sc.one, s.math)
 
-	c := new(uint256.Int).Add(Big1e6, ddn)
+	c := sc.one.Add(ddn)
 	if !ray.refract {
-		c = new(uint256.Int).SDiv(refraction.Dot(normal), Big1e6)
-		c.Sub(Big1e6, c)
+		c = sc.one.Sub(refraction.dot(normal).Div(sc.one))
 	}
 
-	temp = new(uint256.Int).Sub(Big1e6, Big4e4)
-	temp.Mul(temp, c)
-	temp.Mul(temp, c)
-	temp.Mul(temp, c)
-	temp.Mul(temp, c)
-	temp.Mul(temp, c)
-	temp.SDiv(temp, Big1e30)
-	re := new(uint256.Int).Add(Big4e4, temp)
+	// Schlick's approximation of the Fresnel reflectance, the largest
+	// intermediate of the tracer. Dividing by the factors of one⁵ truncates
+	// like dividing by one⁵, which does not fit in an int64.
+	d := diagnose("fresnel")
+	temp = diagSub(d, sc.one, sc.fresnel0)
+	for i := 0; i < 5; i++ {
+		temp = diagMul(d, temp, c)
+	}
+	temp = diagDiv(d, diagDiv(d, diagDiv(d, temp, sc.one), sc.sq), sc.sq)
+	re := sc.fresnel0.Add(temp)
+	transmit := sc.one.Sub(re)
 
 	if ray.depth <= 2 {
-		refraction = s.radiance(&Ray{intersect, refraction, ray.depth, !ray.refract}).ScaleMul(new(uint256.Int).Sub(Big1e6, re))
-		refraction = refraction.Add(s.specular(ray, intersect, normal).ScaleMul(re))
-		return refraction.ScaleDiv(Big1e6)
+		result := s.radiance(&numRay[T]{intersect, refraction, ray.depth, !ray.refract}).scaleMul(transmit)
+		result = result.add(s.specular(ray, intersect, normal).scaleMul(re))
+		return result.scaleDiv(sc.one)
 	}
 
-	reDiv2 := new(uint256.Int).SDiv(re, Big2)
-	threshold := uint256.NewInt(250000)
-	threshold.Add(threshold, reDiv2)
+	reDiv2 := re.Div(sc.two)
+	threshold := sc.quarter.Add(reDiv2)
 
-	if Cmp(new(uint256.Int).SMod(s.rand(), Big1e6), threshold) < 0 {
-		return s.specular(ray, intersect, normal).ScaleMul(re).ScaleDiv(threshold)
+	if s.randScaled(1e6).Cmp(threshold) < 0 {
+		return s.specular(ray, intersect, normal).scaleMul(re).scaleDiv(threshold)
 	}
 
-	return s.radiance(&Ray{intersect, refraction, ray.depth, !ray.refract}).
-		ScaleMul(new(uint256.Int).Sub(Big1e6, re)).
-		ScaleDiv(new(uint256.Int).Sub(uint256.NewInt(750000), reDiv2))
+	return s.radiance(&numRay[T]{intersect, refraction, ray.depth, !ray.refract}).
+		scaleMul(transmit).
+		scaleDiv(sc.threeQtrs.Sub(reDiv2))
 }
 
-func (s *Scene) traceRay(ray *Ray) (*uint256.Int, Primitive, int) {
-	var p Primitive
-	var id int
-
-	dist := NewBig0()
+func (s *SceneOf[T]) traceRay(ray *numRay[T]) (dist T, p Primitive, id int) {
+	if s.bvh != nil {
+		return s.bvh.traceRay(s, ray)
+	}
 
-	for i := 0; i < len(s.spheres); i++ {
-		d := s.spheres[i].Intersect(ray)
-		if Cmp(d, Big0) > 0 && (Cmp(dist, Big0) == 0 || Cmp(d, dist) < 0) {
-			dist.Set(d)
+	for i := range s.numSpheres {
+		d := s.numSpheres[i].intersect(ray, s.sc, s.math)
+		if d.Cmp(s.sc.zero) > 0 && (dist.Cmp(s.sc.zero) == 0 || d.Cmp(dist) < 0) {
+			dist = d
 			p = SpherePrimitive
 			id = i
 		}
 	}
 
-	for i := 0; i < len(s.triangles); i++ {
-		d := s.triangles[i].Intersect(ray)
-		if Cmp(d, Big0) > 0 && (Cmp(dist, Big0) == 0 || Cmp(d, dist) < 0) {
-			dist.Set(d)
+	for i := range s.numTriangles {
+		d := s.numTriangles[i].intersect(ray, s.sc)
+		if d.Cmp(s.sc.zero) > 0 && (dist.Cmp(s.sc.zero) == 0 || d.Cmp(dist) < 0) {
+			dist = d
 			p = TrianglePrimitive
 			id = i
 		}
@@ -369,6 +501,49 @@ func (s *Scene) traceRay(ray *Ray) (*uint256.Int, Primitive, int) {
 	return dist, p, id
 }
 
-func (s *Scene) Trace(x, y, spp int) Vector {
-	return s.trace(x, y, spp)
+func (s *SceneOf[T]) Width() int {
+	return s.width
+}
+
+func (s *SceneOf[T]) Height() int {
+	return s.height
+}
+
+func (s *SceneOf[T]) Trace(x, y, spp int) Vector {
+	return vectorOf(s.trace(x, y, spp))
+}
+
+// TracePixel traces a single pixel and returns its RGB values the same way the
+// contract's TracePixel does.
+func (s *SceneOf[T]) TracePixel(x, y, spp int) (r, g, b byte) {
+	color := s.trace(x, y, spp)
+	return byte(color[0].Int()), byte(color[1].Int()), byte(color[2].Int())
+}
+
+// TraceScanline traces a single horizontal scanline of the image and returns the
+// RGB pixel value array, left-to-right. The layout matches the contract's
+// TraceScanline on a freshly initialized contract (the contract appends to a
+// storage buffer that is never cleared between calls).
+func (s *SceneOf[T]) TraceScanline(y, spp int) []byte {
+	buffer := make([]byte, 0, 3*s.width)
+	return s.appendScanline(buffer, y, spp)
+}
+
+// TraceImage traces the entire image and returns the RGB pixel value array
+// containing all the data top-down, left-to-right, like the contract's
+// TraceImage.
+func (s *SceneOf[T]) TraceImage(spp int) []byte {
+	buffer := make([]byte, 0, 3*s.width*s.height)
+	for y := s.height - 1; y >= 0; y-- {
+		buffer = s.appendScanline(buffer, y, spp)
+	}
+	return buffer
+}
+
+func (s *SceneOf[T]) appendScanline(buffer []byte, y, spp int) []byte {
+	for x := 0; x < s.width; x++ {
+		r, g, b := s.TracePixel(x, y, spp)
+		buffer = append(buffer, r, g, b)
+	}
+	return buffer
 }
@@ -0,0 +1,44 @@
+package snailtracer
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newLowResScene returns the benchmark scene at a resolution small enough to
+// trace every pixel in a test.
+func newLowResScene(tb testing.TB, width, height int) *Scene {
+	s, err := newBenchmarkSceneBuilder(true).Resolution(width, height).Build(0, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+func TestTraceScanline(t *testing.T) {
+	s := newLowResScene(t, 16, 12)
+	line := s.TraceScanline(5, 2)
+	if len(line) != 3*16 {
+		t.Fatalf("have %d bytes, want %d", len(line), 3*16)
+	}
+	for x := 0; x < 16; x++ {
+		color := s.Trace(x, 5, 2)
+		want := []byte{byte(color.X.Uint64()), byte(color.Y.Uint64()), byte(color.Z.Uint64())}
+		if !bytes.Equal(line[3*x:3*x+3], want) {
+			t.Errorf("pixel %d: have %v, want %v", x, line[3*x:3*x+3], want)
+		}
+	}
+}
+
+func TestTraceImage(t *testing.T) {
+	s := newLowResScene(t, 8, 6)
+	img := s.TraceImage(1)
+
+	var want []byte
+	for y := 5; y >= 0; y-- {
+		want = append(want, s.TraceScanline(y, 1)...)
+	}
+	if !bytes.Equal(img, want) {
+		t.Fatalf("image is not the top-down concatenation of its scanlines")
+	}
+}
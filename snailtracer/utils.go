@@ -42,74 +42,110 @@ func NewBig1e12() *uint256.Int {
 }
 
 func Abs(x *uint256.Int) *uint256.Int {
+	z := abs256(x)
+	return &z
+}
+
+// abs256 is Abs without allocating.
+func abs256(x *uint256.Int) (z uint256.Int) {
 	if x.Sign() > 0 {
-		return new(uint256.Int).Set(x)
+		return *x
 	}
-	return new(uint256.Int).Neg(x)
+	z.Neg(x)
+	return z
 }
 
 func Clamp(x *uint256.Int) *uint256.Int {
+	z := clamp(x, Big1e6)
+	return &z
+}
+
+// clamp is Clamp to [0, max] without allocating.
+func clamp(x, max *uint256.Int) uint256.Int {
+	diagnose("Clamp").value(x)
 	if Cmp(x, Big0) < 0 {
-		return NewBig0()
+		return uint256.Int{}
 	}
-	if Cmp(x, Big1e6) > 0 {
-		return NewBig1e6()
+	if Cmp(x, max) > 0 {
+		return *max
 	}
-	return new(uint256.Int).Set(x)
+	return *x
 }
 
 func Sqrt(x *uint256.Int) *uint256.Int {
-	z := new(uint256.Int).Add(x, Big1)
-	z.SDiv(z, Big2)
-	y := new(uint256.Int).Set(x)
-	for Cmp(z, y) < 0 {
-		y.Set(z)
-		z.SDiv(x, y)
-		z.Add(z, y)
-		z.SDiv(z, Big2)
+	y := sqrt(x)
+	return &y
+}
+
+// sqrt is Sqrt without allocating.
+func sqrt(x *uint256.Int) (y uint256.Int) {
+	d := diagnose("Sqrt")
+	var z uint256.Int
+	d.add(&z, x, Big1)
+	d.sdiv(&z, &z, Big2)
+	y.Set(x)
+	for Cmp(&z, &y) < 0 {
+		y.Set(&z)
+		d.sdiv(&z, x, &y)
+		d.add(&z, &z, &y)
+		d.sdiv(&z, &z, Big2)
 	}
 	return y
 }
 
+// Sin returns the sine of x, after reducing x in place to [0, 2π).
 func Sin(x *uint256.Int) *uint256.Int {
-	constant := uint256.NewInt(6283184)
+	y := sin(x, DefaultScale)
+	return &y
+}
+
+// sin is Sin at the scale sc without allocating. It reduces x in place too.
+func sin(x *uint256.Int, sc *Scale) (y uint256.Int) {
+	d := diagnose("Sin")
 	for x.Sign() < 0 {
-		x.Add(x, constant)
+		d.add(x, x, sc.twoPi)
 	}
-	for Cmp(x, constant) >= 0 {
-		x.Sub(x, constant)
+	for Cmp(x, sc.twoPi) >= 0 {
+		d.sub(x, x, sc.twoPi)
 	}
 
-	n := new(uint256.Int).Set(x)
-	y := NewBig0()
-	s := NewBig1()
-	d := NewBig1()
-	f := NewBig2()
-	for Cmp(n, d) > 0 {
-		t := new(uint256.Int).Mul(s, n)
-		t.SDiv(t, d)
-		y.Add(y, t)
+	var t uint256.Int
+	n := *x
+	s := *Big1
+	q := *Big1
+	f := *Big2
+	for Cmp(&n, &q) > 0 {
+		d.mul(&t, &s, &n)
+		d.sdiv(&t, &t, &q)
+		d.add(&y, &y, &t)
 
-		n.Mul(n, x)
-		n.Mul(n, x)
-		n.SDiv(n, Big1e6)
-		n.SDiv(n, Big1e6)
+		d.mul(&n, &n, x)
+		d.mul(&n, &n, x)
+		d.sdiv(&n, &n, sc.one)
+		d.sdiv(&n, &n, sc.one)
 
-		d.Mul(d, f)
-		d.Mul(d, new(uint256.Int).Add(f, Big1))
+		d.mul(&q, &q, &f)
+		d.mul(&q, &q, d.add(&t, &f, Big1))
 
-		s.Neg(s)
+		d.neg(&s, &s)
 
-		f.Add(f, Big2)
+		d.add(&f, &f, Big2)
 	}
 	return y
 }
 
 func Cos(x *uint256.Int) *uint256.Int {
-	s := Sin(x)
-	sSquared := new(uint256.Int).Mul(s, s)
-	difference := new(uint256.Int).Sub(Big1e12, sSquared)
-	return Sqrt(difference)
+	y := cos(x, DefaultScale)
+	return &y
+}
+
+// cos is Cos at the scale sc without allocating.
+func cos(x *uint256.Int, sc *Scale) uint256.Int {
+	s := sin(x, sc)
+	d := diagnose("Cos")
+	d.mul(&s, &s, &s)
+	d.sub(&s, sc.sq, &s)
+	return sqrt(&s)
 }
 
 func Cmp(x, y *uint256.Int) int {
@@ -0,0 +1,239 @@
+package snailtracer
+
+import (
+	"github.com/holiman/uint256"
+)
+
+// Vec is a Vector stored by value, for arithmetic that does not allocate. Like
+// uint256.Int, its methods set the receiver to the result and return it, and
+// the receiver may alias the operands.
+type Vec [3]uint256.Int
+
+func NewVec(x, y, z int64) Vec {
+	var v Vec
+	setInt64(&v[0], x)
+	setInt64(&v[1], y)
+	setInt64(&v[2], z)
+	return v
+}
+
+func setInt64(z *uint256.Int, n int64) {
+	z.SetUint64(uint64(abs(n)))
+	if n < 0 {
+		z.Neg(z)
+	}
+}
+
+// SetVector sets z to the value of v.
+func (z *Vec) SetVector(v Vector) *Vec {
+	z[0].Set(v.X)
+	z[1].Set(v.Y)
+	z[2].Set(v.Z)
+	return z
+}
+
+// Vector returns a copy of v as a Vector.
+func (v *Vec) Vector() Vector {
+	return Vector{
+		new(uint256.Int).Set(&v[0]),
+		new(uint256.Int).Set(&v[1]),
+		new(uint256.Int).Set(&v[2]),
+	}
+}
+
+func (z *Vec) Add(x, y *Vec) *Vec {
+	d := diagnose("Vec.Add")
+	for i := range z {
+		d.add(&z[i], &x[i], &y[i])
+	}
+	return z
+}
+
+func (z *Vec) Sub(x, y *Vec) *Vec {
+	d := diagnose("Vec.Sub")
+	for i := range z {
+		d.sub(&z[i], &x[i], &y[i])
+	}
+	return z
+}
+
+func (z *Vec) ScaleMul(x *Vec, m *uint256.Int) *Vec {
+	d := diagnose("Vec.ScaleMul")
+	for i := range z {
+		d.mul(&z[i], m, &x[i])
+	}
+	return z
+}
+
+func (z *Vec) ScaleDiv(x *Vec, m *uint256.Int) *Vec {
+	d := diagnose("Vec.ScaleDiv")
+	for i := range z {
+		d.sdiv(&z[i], &x[i], m)
+	}
+	return z
+}
+
+func (z *Vec) Mul(x, y *Vec) *Vec {
+	d := diagnose("Vec.Mul")
+	for i := range z {
+		d.mul(&z[i], &x[i], &y[i])
+	}
+	return z
+}
+
+func (z *Vec) Cross(x, y *Vec) *Vec {
+	d := diagnose("Vec.Cross")
+	var c Vec
+	var t uint256.Int
+	d.sub(&c[0], d.mul(&c[0], &x[1], &y[2]), d.mul(&t, &x[2], &y[1]))
+	d.sub(&c[1], d.mul(&c[1], &x[2], &y[0]), d.mul(&t, &x[0], &y[2]))
+	d.sub(&c[2], d.mul(&c[2], &x[0], &y[1]), d.mul(&t, &x[1], &y[0]))
+	*z = c
+	return z
+}
+
+func (z *Vec) Norm(x *Vec) *Vec {
+	return z.norm(x, Big1e6, ContractMath)
+}
+
+// norm is Norm at the fixed-point scale of one, with the square root of m.
+func (z *Vec) norm(x *Vec, one *uint256.Int, m MathMode) *Vec {
+	length := x.length(m)
+	if length.IsZero() {
+		*z = Vec{}
+		return z
+	}
+	d := diagnose("Vec.Norm")
+	for i := range z {
+		d.mul(&z[i], &x[i], one)
+		d.sdiv(&z[i], &z[i], &length)
+	}
+	return z
+}
+
+func (z *Vec) Clamp(x *Vec) *Vec {
+	return z.clamp(x, Big1e6)
+}
+
+// clamp is Clamp to [0, max].
+func (z *Vec) clamp(x *Vec, max *uint256.Int) *Vec {
+	for i := range z {
+		z[i] = clamp(&x[i], max)
+	}
+	return z
+}
+
+// Dot returns the dot product of v and u.
+func (v *Vec) Dot(u *Vec) uint256.Int {
+	d := diagnose("Vec.Dot")
+	var p, t uint256.Int
+	d.mul(&p, &v[0], &u[0])
+	d.add(&p, &p, d.mul(&t, &v[1], &u[1]))
+	d.add(&p, &p, d.mul(&t, &v[2], &u[2]))
+	return p
+}
+
+// Length returns the length of v.
+func (v *Vec) Length() uint256.Int {
+	return v.length(ContractMath)
+}
+
+// length is Length with the square root of m.
+func (v *Vec) length(m MathMode) uint256.Int {
+	d := v.Dot(v)
+	return m.sqrt(&d)
+}
+
+// numVector is Vec on the number representation T, which the tracer computes
+// on. It is stored and returned by value, so it does not allocate either.
+type numVector[T Num[T]] [3]T
+
+func numVectorOf[T Num[T]](v Vector) numVector[T] {
+	return numVector[T]{numOf[T](v.X), numOf[T](v.Y), numOf[T](v.Z)}
+}
+
+// vectorOf returns v as a Vector, exactly for Int256 and truncated to integers
+// for other representations.
+func vectorOf[T Num[T]](v numVector[T]) Vector {
+	if z, ok := any(v).(numVector[Int256]); ok {
+		var vec Vec
+		for i := range vec {
+			vec[i] = z[i].v
+		}
+		return vec.Vector()
+	}
+	return NewVector(v[0].Int(), v[1].Int(), v[2].Int())
+}
+
+func (v numVector[T]) add(u numVector[T]) numVector[T] {
+	d := diagnose("Vec.Add")
+	return numVector[T]{diagAdd(d, v[0], u[0]), diagAdd(d, v[1], u[1]), diagAdd(d, v[2], u[2])}
+}
+
+func (v numVector[T]) sub(u numVector[T]) numVector[T] {
+	d := diagnose("Vec.Sub")
+	return numVector[T]{diagSub(d, v[0], u[0]), diagSub(d, v[1], u[1]), diagSub(d, v[2], u[2])}
+}
+
+func (v numVector[T]) scaleMul(m T) numVector[T] {
+	d := diagnose("Vec.ScaleMul")
+	return numVector[T]{diagMul(d, m, v[0]), diagMul(d, m, v[1]), diagMul(d, m, v[2])}
+}
+
+func (v numVector[T]) scaleDiv(m T) numVector[T] {
+	d := diagnose("Vec.ScaleDiv")
+	return numVector[T]{diagDiv(d, v[0], m), diagDiv(d, v[1], m), diagDiv(d, v[2], m)}
+}
+
+func (v numVector[T]) mul(u numVector[T]) numVector[T] {
+	d := diagnose("Vec.Mul")
+	return numVector[T]{diagMul(d, v[0], u[0]), diagMul(d, v[1], u[1]), diagMul(d, v[2], u[2])}
+}
+
+func (v numVector[T]) cross(u numVector[T]) numVector[T] {
+	d := diagnose("Vec.Cross")
+	return numVector[T]{
+		diagSub(d, diagMul(d, v[1], u[2]), diagMul(d, v[2], u[1])),
+		diagSub(d, diagMul(d, v[2], u[0]), diagMul(d, v[0], u[2])),
+		diagSub(d, diagMul(d, v[0], u[1]), diagMul(d, v[1], u[0])),
+	}
+}
+
+// norm is Vec.norm: v normalized to the fixed-point length one, with the
+// square root of m.
+func (v numVector[T]) norm(one T, m MathMode) numVector[T] {
+	length := v.dot(v).Sqrt(m)
+	var zero T
+	if length.Cmp(zero) == 0 {
+		return numVector[T]{}
+	}
+	d := diagnose("Vec.Norm")
+	return numVector[T]{
+		diagDiv(d, diagMul(d, v[0], one), length),
+		diagDiv(d, diagMul(d, v[1], one), length),
+		diagDiv(d, diagMul(d, v[2], one), length),
+	}
+}
+
+// clamp is Vec.clamp to [0, max].
+func (v numVector[T]) clamp(max T) numVector[T] {
+	return numVector[T]{numClamp(v[0], max), numClamp(v[1], max), numClamp(v[2], max)}
+}
+
+func numClamp[T Num[T]](x, max T) T {
+	diagValue(diagnose("Clamp"), x)
+	var zero T
+	if x.Cmp(zero) < 0 {
+		return zero
+	}
+	if x.Cmp(max) > 0 {
+		return max
+	}
+	return x
+}
+
+// dot returns the dot product of v and u.
+func (v numVector[T]) dot(u numVector[T]) T {
+	d := diagnose("Vec.Dot")
+	return diagAdd(d, diagMul(d, v[0], u[0]), diagAdd(d, diagMul(d, v[1], u[1]), diagMul(d, v[2], u[2])))
+}
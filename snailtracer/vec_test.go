@@ -0,0 +1,110 @@
+//go:build !tinygo && !wasip1
+
+package snailtracer
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomVectors(n int) []Vector {
+	rng := rand.New(rand.NewSource(1))
+	coord := func() int64 { return rng.Int63n(2e8) - 1e8 }
+	vectors := make([]Vector, n)
+	for i := range vectors {
+		vectors[i] = NewVector(coord(), coord(), coord())
+	}
+	return vectors
+}
+
+func equalVec(v *Vec, u Vector) bool {
+	return v[0].Eq(u.X) && v[1].Eq(u.Y) && v[2].Eq(u.Z)
+}
+
+// TestVec checks that every Vec method computes the same as its Vector
+// counterpart, also when the receiver aliases an operand.
+func TestVec(t *testing.T) {
+	vectors := randomVectors(32)
+	m := NewBig1e3()
+	for i := 1; i < len(vectors); i++ {
+		v, u := vectors[i-1], vectors[i]
+		for name, test := range map[string]struct {
+			have func(x, y *Vec) *Vec
+			want Vector
+		}{
+			"add":      {func(x, y *Vec) *Vec { return x.Add(x, y) }, v.Add(u)},
+			"sub":      {func(x, y *Vec) *Vec { return x.Sub(x, y) }, v.Sub(u)},
+			"mul":      {func(x, y *Vec) *Vec { return x.Mul(x, y) }, v.Mul(u)},
+			"cross":    {func(x, y *Vec) *Vec { return x.Cross(x, y) }, v.Cross(u)},
+			"scaleMul": {func(x, _ *Vec) *Vec { return x.ScaleMul(x, m) }, v.ScaleMul(m)},
+			"scaleDiv": {func(x, _ *Vec) *Vec { return x.ScaleDiv(x, m) }, v.ScaleDiv(m)},
+			"norm":     {func(x, _ *Vec) *Vec { return x.Norm(x) }, v.Norm()},
+			"clamp":    {func(x, _ *Vec) *Vec { return x.Clamp(x) }, v.Clamp()},
+		} {
+			var x, y Vec
+			x.SetVector(v)
+			y.SetVector(u)
+			if have := test.have(&x, &y); !equalVec(have, test.want) {
+				t.Errorf("%s(%v, %v): have %v, want %v", name, v, u, have.Vector(), test.want)
+			}
+		}
+		var x, y Vec
+		x.SetVector(v)
+		y.SetVector(u)
+		if have, want := x.Dot(&y), v.Dot(u); !have.Eq(want) {
+			t.Errorf("dot(%v, %v): have %v, want %v", v, u, &have, want)
+		}
+		if have, want := x.Length(), v.Length(); !have.Eq(want) {
+			t.Errorf("length(%v): have %v, want %v", v, &have, want)
+		}
+	}
+}
+
+func TestTracePixelAllocs(t *testing.T) {
+	s := NewBenchmarkScene(0, 0)
+	for _, p := range BenchmarkPixels {
+		if allocs := testing.AllocsPerRun(1, func() { s.TracePixel(p.X, p.Y, p.SPP) }); allocs != 0 {
+			t.Errorf("pixel (%d, %d): have %v allocations, want 0", p.X, p.Y, allocs)
+		}
+	}
+}
+
+// BenchmarkTracePixel traces the benchmark pixels, reporting allocations.
+func BenchmarkTracePixel(b *testing.B) {
+	s := NewBenchmarkScene(0, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, p := range BenchmarkPixels {
+			s.TracePixel(p.X, p.Y, p.SPP)
+		}
+	}
+}
+
+// BenchmarkVectorOps compares the allocating Vector arithmetic with the
+// in-place Vec arithmetic on the operations of a diffuse bounce.
+func BenchmarkVectorOps(b *testing.B) {
+	vectors := randomVectors(64)
+	b.Run("Vector", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v, u := vectors[i%len(vectors)], vectors[(i+1)%len(vectors)]
+			n := v.Cross(u).Norm()
+			_ = n.Add(u.ScaleMul(n.Dot(v)).ScaleDiv(Big1e6)).Norm()
+		}
+	})
+	vecs := make([]Vec, len(vectors))
+	for i, v := range vectors {
+		vecs[i].SetVector(v)
+	}
+	b.Run("Vec", func(b *testing.B) {
+		b.ReportAllocs()
+		var n, t Vec
+		for i := 0; i < b.N; i++ {
+			v, u := &vecs[i%len(vecs)], &vecs[(i+1)%len(vecs)]
+			n.Cross(v, u).Norm(&n)
+			d := n.Dot(v)
+			t.ScaleMul(u, &d).ScaleDiv(&t, Big1e6)
+			n.Add(&n, &t).Norm(&n)
+		}
+	})
+}
@@ -32,87 +32,101 @@ func NewVector(x, y, z int64) Vector {
 }
 
 func (v Vector) Add(u Vector) Vector {
+	d := diagnose("Vector.Add")
 	return Vector{
-		new(uint256.Int).Add(v.X, u.X),
-		new(uint256.Int).Add(v.Y, u.Y),
-		new(uint256.Int).Add(v.Z, u.Z),
+		d.add(new(uint256.Int), v.X, u.X),
+		d.add(new(uint256.Int), v.Y, u.Y),
+		d.add(new(uint256.Int), v.Z, u.Z),
 	}
 }
 
 func (v Vector) Sub(u Vector) Vector {
+	d := diagnose("Vector.Sub")
 	return Vector{
-		new(uint256.Int).Sub(v.X, u.X),
-		new(uint256.Int).Sub(v.Y, u.Y),
-		new(uint256.Int).Sub(v.Z, u.Z),
+		d.sub(new(uint256.Int), v.X, u.X),
+		d.sub(new(uint256.Int), v.Y, u.Y),
+		d.sub(new(uint256.Int), v.Z, u.Z),
 	}
 }
 
 func (v Vector) ScaleMul(m *uint256.Int) Vector {
+	d := diagnose("Vector.ScaleMul")
 	return Vector{
-		new(uint256.Int).Mul(m, v.X),
-		new(uint256.Int).Mul(m, v.Y),
-		new(uint256.Int).Mul(m, v.Z),
+		d.mul(new(uint256.Int), m, v.X),
+		d.mul(new(uint256.Int), m, v.Y),
+		d.mul(new(uint256.Int), m, v.Z),
 	}
 }
 
-func (v Vector) ScaleDiv(d *uint256.Int) Vector {
+func (v Vector) ScaleDiv(m *uint256.Int) Vector {
+	d := diagnose("Vector.ScaleDiv")
 	return Vector{
-		new(uint256.Int).SDiv(v.X, d),
-		new(uint256.Int).SDiv(v.Y, d),
-		new(uint256.Int).SDiv(v.Z, d),
+		d.sdiv(new(uint256.Int), v.X, m),
+		d.sdiv(new(uint256.Int), v.Y, m),
+		d.sdiv(new(uint256.Int), v.Z, m),
 	}
 }
 
 func (v Vector) Mul(u Vector) Vector {
+	d := diagnose("Vector.Mul")
 	return Vector{
-		new(uint256.Int).Mul(v.X, u.X),
-		new(uint256.Int).Mul(v.Y, u.Y),
-		new(uint256.Int).Mul(v.Z, u.Z),
+		d.mul(new(uint256.Int), v.X, u.X),
+		d.mul(new(uint256.Int), v.Y, u.Y),
+		d.mul(new(uint256.Int), v.Z, u.Z),
 	}
 }
 
 func (v Vector) Dot(u Vector) *uint256.Int {
-	return new(uint256.Int).Add(
-		new(uint256.Int).Mul(v.X, u.X),
-		new(uint256.Int).Add(
-			new(uint256.Int).Mul(v.Y, u.Y),
-			new(uint256.Int).Mul(v.Z, u.Z),
+	d := diagnose("Vector.Dot")
+	return d.add(new(uint256.Int),
+		d.mul(new(uint256.Int), v.X, u.X),
+		d.add(new(uint256.Int),
+			d.mul(new(uint256.Int), v.Y, u.Y),
+			d.mul(new(uint256.Int), v.Z, u.Z),
 		),
 	)
 }
 
 func (v Vector) Cross(u Vector) Vector {
+	d := diagnose("Vector.Cross")
 	return Vector{
-		new(uint256.Int).Sub(
-			new(uint256.Int).Mul(v.Y, u.Z),
-			new(uint256.Int).Mul(v.Z, u.Y),
+		d.sub(new(uint256.Int),
+			d.mul(new(uint256.Int), v.Y, u.Z),
+			d.mul(new(uint256.Int), v.Z, u.Y),
 		),
-		new(uint256.Int).Sub(
-			new(uint256.Int).Mul(v.Z, u.X),
-			new(uint256.Int).Mul(v.X, u.Z),
+		d.sub(new(uint256.Int),
+			d.mul(new(uint256.Int), v.Z, u.X),
+			d.mul(new(uint256.Int), v.X, u.Z),
 		),
-		new(uint256.Int).Sub(
-			new(uint256.Int).Mul(v.X, u.Y),
-			new(uint256.Int).Mul(v.Y, u.X),
+		d.sub(new(uint256.Int),
+			d.mul(new(uint256.Int), v.X, u.Y),
+			d.mul(new(uint256.Int), v.Y, u.X),
 		),
 	}
 }
 
 func (v Vector) Length() *uint256.Int {
-	xSq := new(uint256.Int).Mul(v.X, v.X)
-	ySq := new(uint256.Int).Mul(v.Y, v.Y)
-	zSq := new(uint256.Int).Mul(v.Z, v.Z)
-	return Sqrt(new(uint256.Int).Add(xSq, new(uint256.Int).Add(ySq, zSq)))
+	d := diagnose("Vector.Length")
+	xSq := d.mul(new(uint256.Int), v.X, v.X)
+	ySq := d.mul(new(uint256.Int), v.Y, v.Y)
+	zSq := d.mul(new(uint256.Int), v.Z, v.Z)
+	return Sqrt(d.add(new(uint256.Int), xSq, d.add(new(uint256.Int), ySq, zSq)))
 }
 
 func (v Vector) Norm() Vector {
+	return v.norm(Big1e6)
+}
+
+// norm is Norm at the fixed-point scale of one.
+func (v Vector) norm(one *uint256.Int) Vector {
 	length := v.Length()
 	if Cmp(length, Big0) == 0 {
 		return Vector{NewBig0(), NewBig0(), NewBig0()}
 	}
-	nx := new(uint256.Int).SDiv(new(uint256.Int).Mul(v.X, Big1e6), length)
-	ny := new(uint256.Int).SDiv(new(uint256.Int).Mul(v.Y, Big1e6), length)
-	nz := new(uint256.Int).SDiv(new(uint256.Int).Mul(v.Z, Big1e6), length)
+	d := diagnose("Vector.Norm")
+	nx := d.sdiv(new(uint256.Int), d.mul(new(uint256.Int), v.X, one), length)
+	ny := d.sdiv(new(uint256.Int), d.mul(new(uint256.Int), v.Y, one), length)
+	nz := d.sdiv(new(uint256.Int), d.mul(new(uint256.Int), v.Z, one), length)
 	return Vector{nx, ny, nz}
 }
 
@@ -1,35 +1,52 @@
+// Command tinygo is the snailtracer built with TinyGo. The exported functions
+// are implemented in package wasmexports.
 package main
 
-import (
-	"github.com/holiman/uint256"
-	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
-)
-
-var (
-	scene *snailtracer.Scene
-)
+import "github.com/therealbytes/snailtracer-benchmark/wasmexports"
 
 //export run
 func run(seed int32) int32 {
-	if scene == nil {
-		// Global variables behave unexpectedly in Wasmer, so we need to initialize
-		// the scene here.
-		scene = snailtracer.NewBenchmarkScene(0, int(seed))
-	}
-
-	color := snailtracer.NewVector(0, 0, 0)
-	color = color.Add(scene.Trace(512, 384, 8))
-	color = color.Add(scene.Trace(325, 540, 8))
-	color = color.Add(scene.Trace(600, 600, 8))
-	color = color.Add(scene.Trace(522, 524, 8))
-	color = color.ScaleDiv(uint256.NewInt(4))
-
-	cr := color.X.Uint64() & 0xff
-	cg := color.Y.Uint64() & 0xff
-	cb := color.Z.Uint64() & 0xff
-
-	// return int32(cr) + int32(cg), int32(cb)
-	return int32(cr<<16 + cg<<8 + cb)
+	return wasmexports.Run(seed)
+}
+
+//export trace_pixel
+func tracePixel(x, y, spp int32) int32 {
+	return wasmexports.TracePixel(x, y, spp)
+}
+
+//export set_scale
+func setScale(digits int32) int32 {
+	return wasmexports.SetScale(digits)
+}
+
+//export set_math
+func setMath(mode int32) {
+	wasmexports.SetMath(mode)
+}
+
+//export image_width
+func imageWidth() int32 {
+	return wasmexports.ImageWidth()
+}
+
+//export image_height
+func imageHeight() int32 {
+	return wasmexports.ImageHeight()
+}
+
+//export trace_scanline
+func traceScanline(y, spp int32) int32 {
+	return wasmexports.TraceScanline(y, spp)
+}
+
+//export trace_image
+func traceImage(spp int32) int32 {
+	return wasmexports.TraceImage(spp)
+}
+
+//export host_calls
+func hostCalls(n int32) {
+	wasmexports.HostCalls(n)
 }
 
 // main is REQUIRED for TinyGo to compile to WASM
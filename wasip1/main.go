@@ -0,0 +1,58 @@
+//go:build wasip1
+
+// Command wasip1 is the snailtracer built for GOOS=wasip1 GOARCH=wasm with the
+// standard Go toolchain, exporting the same functions as the TinyGo build from
+// package wasmexports. It has to be built with -buildmode=c-shared so that the
+// module can be called after its _initialize export, and needs Go 1.24 or
+// later for go:wasmexport.
+package main
+
+import "github.com/therealbytes/snailtracer-benchmark/wasmexports"
+
+//go:wasmexport run
+func run(seed int32) int32 {
+	return wasmexports.Run(seed)
+}
+
+//go:wasmexport trace_pixel
+func tracePixel(x, y, spp int32) int32 {
+	return wasmexports.TracePixel(x, y, spp)
+}
+
+//go:wasmexport set_scale
+func setScale(digits int32) int32 {
+	return wasmexports.SetScale(digits)
+}
+
+//go:wasmexport set_math
+func setMath(mode int32) {
+	wasmexports.SetMath(mode)
+}
+
+//go:wasmexport image_width
+func imageWidth() int32 {
+	return wasmexports.ImageWidth()
+}
+
+//go:wasmexport image_height
+func imageHeight() int32 {
+	return wasmexports.ImageHeight()
+}
+
+//go:wasmexport trace_scanline
+func traceScanline(y, spp int32) int32 {
+	return wasmexports.TraceScanline(y, spp)
+}
+
+//go:wasmexport trace_image
+func traceImage(spp int32) int32 {
+	return wasmexports.TraceImage(spp)
+}
+
+//go:wasmexport host_calls
+func hostCalls(n int32) {
+	wasmexports.HostCalls(n)
+}
+
+// main is not called in c-shared mode, but package main requires it.
+func main() {}
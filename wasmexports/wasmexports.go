@@ -0,0 +1,133 @@
+// Package wasmexports implements the functions the snailtracer Wasm modules
+// export, shared by the TinyGo and the wasip1 builds. Each build exports them
+// with its own toolchain's directive.
+package wasmexports
+
+import (
+	"strconv"
+	"unsafe"
+
+	"github.com/holiman/uint256"
+	"github.com/therealbytes/snailtracer-benchmark/snailtracer"
+	"github.com/therealbytes/snailtracer-benchmark/wasmhost"
+)
+
+var (
+	scene *snailtracer.Scene
+
+	// buffer holds the pixels traced by TraceScanline and TraceImage. It is
+	// kept in a global so that it stays valid until the next call.
+	buffer []byte
+)
+
+// getScene returns the benchmark scene, creating it on first use.
+func getScene(seed int32) *snailtracer.Scene {
+	if scene == nil {
+		// Global variables behave unexpectedly in Wasmer, so we need to initialize
+		// the scene here.
+		scene = snailtracer.NewBenchmarkScene(0, int(seed))
+	}
+	return scene
+}
+
+// Run traces the benchmark pixels and returns their average color packed as
+// 0xRRGGBB.
+func Run(seed int32) int32 {
+	scene := getScene(seed)
+
+	color := snailtracer.NewVector(0, 0, 0)
+	color = color.Add(scene.Trace(512, 384, 8))
+	color = color.Add(scene.Trace(325, 540, 8))
+	color = color.Add(scene.Trace(600, 600, 8))
+	color = color.Add(scene.Trace(522, 524, 8))
+	color = color.ScaleDiv(uint256.NewInt(4))
+
+	cr := color.X.Uint64() & 0xff
+	cg := color.Y.Uint64() & 0xff
+	cb := color.Z.Uint64() & 0xff
+
+	return int32(cr<<16 + cg<<8 + cb)
+}
+
+// TracePixel traces a single pixel of the benchmark scene and returns its RGB
+// values packed like Run does.
+func TracePixel(x, y, spp int32) int32 {
+	r, g, b := getScene(0).TracePixel(int(x), int(y), int(spp))
+	return int32(r)<<16 + int32(g)<<8 + int32(b)
+}
+
+// SetScale retraces the benchmark scene at the fixed-point scale 1e<digits>
+// from then on. It returns 0 if digits is out of range, 1 otherwise.
+func SetScale(digits int32) int32 {
+	sc, err := snailtracer.NewScale(int(digits))
+	if err != nil {
+		return 0
+	}
+	// Rescale the original scene, not one already rescaled to fewer digits
+	mode := getScene(0).Math()
+	scene = snailtracer.NewBenchmarkScene(0, 0)
+	scene.SetMath(mode)
+	scene = scene.Rescale(sc)
+	return 1
+}
+
+// SetMath traces the benchmark scene with the snailtracer.MathMode mode from
+// then on.
+func SetMath(mode int32) {
+	getScene(0).SetMath(snailtracer.MathMode(mode))
+}
+
+// ImageWidth returns the width of the benchmark image in pixels.
+func ImageWidth() int32 {
+	return int32(getScene(0).Width())
+}
+
+// ImageHeight returns the height of the benchmark image in pixels.
+func ImageHeight() int32 {
+	return int32(getScene(0).Height())
+}
+
+// TraceScanline traces scanline y of the benchmark scene and returns the
+// address in linear memory of its 3*ImageWidth() RGB bytes, left-to-right.
+// It reports its progress to the host after every pixel.
+func TraceScanline(y, spp int32) int32 {
+	scene := getScene(0)
+	width := scene.Width()
+	pixels := make([]byte, 0, 3*width)
+	for x := 0; x < width; x++ {
+		r, g, b := scene.TracePixel(x, int(y), int(spp))
+		pixels = append(pixels, r, g, b)
+		wasmhost.ReportProgress(x+1, width)
+	}
+	return setBuffer(pixels)
+}
+
+// TraceImage traces the whole benchmark image and returns the address in
+// linear memory of its 3*ImageWidth()*ImageHeight() RGB bytes, top-down,
+// left-to-right. It reports its progress to the host after every scanline.
+func TraceImage(spp int32) int32 {
+	scene := getScene(0)
+	width, height := scene.Width(), scene.Height()
+	wasmhost.Log("tracing " + strconv.Itoa(width) + "x" + strconv.Itoa(height) + " image at " + strconv.Itoa(int(spp)) + " spp")
+	pixels := make([]byte, 0, 3*width*height)
+	for y := height - 1; y >= 0; y-- {
+		pixels = append(pixels, scene.TraceScanline(y, int(spp))...)
+		wasmhost.ReportProgress((height-y)*width, width*height)
+	}
+	return setBuffer(pixels)
+}
+
+// HostCalls calls the host's report_progress n times, to measure the overhead
+// of host calls.
+func HostCalls(n int32) {
+	for i := int32(1); i <= n; i++ {
+		wasmhost.ReportProgress(int(i), int(n))
+	}
+}
+
+// setBuffer keeps the traced pixels alive until the next call and returns
+// their address.
+func setBuffer(pixels []byte) int32 {
+	buffer = pixels
+	return int32(uintptr(unsafe.Pointer(&buffer[0])))
+}
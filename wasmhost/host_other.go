@@ -0,0 +1,7 @@
+//go:build !wasm
+
+package wasmhost
+
+func reportProgress(done, total int32) {}
+
+func log(ptr *byte, size int32) {}
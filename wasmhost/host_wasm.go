@@ -0,0 +1,9 @@
+package wasmhost
+
+//go:wasmimport env report_progress
+func reportProgress(done, total int32)
+
+// log passes the message's address in linear memory and its length.
+//
+//go:wasmimport env log
+func log(ptr *byte, size int32)
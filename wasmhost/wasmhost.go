@@ -0,0 +1,17 @@
+// Package wasmhost wraps the functions the snailtracer Wasm modules import from
+// the host's "env" module. Outside of Wasm they do nothing.
+package wasmhost
+
+// ReportProgress tells the host that done of total pixels have been traced.
+func ReportProgress(done, total int) {
+	reportProgress(int32(done), int32(total))
+}
+
+// Log sends a message to the host.
+func Log(msg string) {
+	if msg == "" {
+		return
+	}
+	buf := []byte(msg)
+	log(&buf[0], int32(len(buf)))
+}